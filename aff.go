@@ -2,11 +2,13 @@ package gospell
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // AffixType is either an affix prefix or suffix
@@ -28,22 +30,8 @@ type Affix struct {
 // Expand provides all variations of a given word based on this affix rule
 func (a Affix) Expand(word string, out []string) []string {
 	for _, r := range a.Rules {
-		if r.matcher != nil && !r.matcher.MatchString(word) {
-			continue
-		}
-		if a.Type == Prefix {
-			out = append(out, r.AffixText+word)
-			// TODO is does Strip apply to prefixes too?
-		} else {
-			stripWord := word
-			if r.Strip != "" && strings.HasSuffix(word, r.Strip) {
-				stripWord = word[:len(word)-len(r.Strip)]
-			}
-			if r.AffixText == "0" {
-				out = append(out, stripWord)
-			} else {
-				out = append(out, stripWord+r.AffixText)
-			}
+		if w, ok := r.apply(a.Type, word, false); ok {
+			out = append(out, w)
 		}
 	}
 	return out
@@ -53,10 +41,252 @@ func (a Affix) Expand(word string, out []string) []string {
 type Rule struct {
 	Strip     string
 	AffixText string         // suffix or prefix text to add
+	Flags     []rune         // continuation class, e.g. B in "SFX A 0 s/B ."
 	Pattern   string         // original matching pattern from AFF file
 	matcher   *regexp.Regexp // matcher to see if this rule applies or not
 }
 
+// apply returns the word with the rule applied or false if the
+// rule's condition or strip characters do not match
+//
+//	The condition is checked before stripping.  The whole word
+//	can only be stripped with FULLSTRIP
+func (r Rule) apply(atype AffixType, word string, fullStrip bool) (string, bool) {
+	if r.matcher != nil && !r.matcher.MatchString(word) {
+		return "", false
+	}
+	if len(word) < len(r.Strip) || (len(word) == len(r.Strip) && !fullStrip) {
+		return "", false
+	}
+	if atype == Prefix {
+		if !strings.HasPrefix(word, r.Strip) {
+			return "", false
+		}
+		return r.AffixText + word[len(r.Strip):], true
+	}
+	if !strings.HasSuffix(word, r.Strip) {
+		return "", false
+	}
+	return word[:len(word)-len(r.Strip)] + r.AffixText, true
+}
+
+// conditionRegexp converts the condition of an affix rule to a
+// regular expression matching the start (prefix) or end (suffix)
+// of a word.  Conditions only have "." and [] or [^] classes,
+// anything else is a literal character
+func conditionRegexp(cond string, atype AffixType) (*regexp.Regexp, error) {
+	if cond == "." {
+		return nil, nil
+	}
+	pat := strings.Builder{}
+	inClass, classStart := false, false
+	for _, r := range cond {
+		switch {
+		case r == '[' && !inClass:
+			inClass, classStart = true, true
+			pat.WriteRune(r)
+			continue
+		case r == ']' && inClass:
+			inClass = false
+			pat.WriteRune(r)
+		case r == '^' && classStart:
+			pat.WriteRune(r)
+		case r == '.' && !inClass:
+			pat.WriteRune(r)
+		case r == '-' && inClass:
+			pat.WriteString(`\-`)
+		default:
+			pat.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		classStart = false
+	}
+	if inClass {
+		return nil, fmt.Errorf("condition %q has unbalanced brackets", cond)
+	}
+	if atype == Prefix {
+		return regexp.Compile("^" + pat.String())
+	}
+	return regexp.Compile(pat.String() + "$")
+}
+
+// maxSuffixes is the Hunspell limit of suffixes stacked on a stem
+// (twofold suffixes)
+const maxSuffixes = 2
+
+// hasFlag returns true if flag is set and is one of flags
+func hasFlag(flags []rune, flag rune) bool {
+	if flag == 0 {
+		return false
+	}
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// affixed is a word form produced by one or more affix rules
+//
+// ONLYINCOMPOUND affixes are kept in flags, the word form is then
+// only valid inside compounds
+type affixed struct {
+	word      string
+	cross     bool   // every applied suffix allows a cross product
+	suffixed  bool   // at least one suffix was applied
+	flags     []rune // continuation classes of the applied rules
+	needAffix bool   // the outermost affix is NEEDAFFIX
+	circumfix bool   // a CIRCUMFIX affix is missing its counterpart
+}
+
+// valid returns true if the word form is a word on its own
+func (f affixed) valid() bool {
+	return !f.needAffix && !f.circumfix
+}
+
+// ruleState returns the state of a word form with only r applied
+func (a DictConfig) ruleState(r Rule) affixed {
+	return affixed{
+		flags:     r.Flags,
+		needAffix: hasFlag(r.Flags, a.NeedAffixFlag),
+		circumfix: hasFlag(r.Flags, a.CircumfixFlag),
+	}
+}
+
+// prefixed returns the state of a prefix rule applied on top of
+// the affixed form f
+//
+//	A NEEDAFFIX affix is satisfied by the other one, and CIRCUMFIX
+//	prefixes and suffixes must come in pairs
+func (a DictConfig) prefixed(f affixed, r Rule) affixed {
+	p := a.ruleState(r)
+	return affixed{
+		word:      f.word,
+		suffixed:  f.suffixed,
+		flags:     joinFlags(f.flags, p.flags),
+		needAffix: f.needAffix && p.needAffix,
+		circumfix: f.circumfix != p.circumfix,
+	}
+}
+
+// prefixForms applies the prefix rule r on top of the form f and,
+// while depth allows it, the prefixes of r's continuation class on
+// top of the result.  Valid forms are appended to out
+func (a DictConfig) prefixForms(f affixed, r Rule, keys []rune, depth int, out []Form) []Form {
+	if depth == 0 {
+		return out
+	}
+	w, ok := r.apply(Prefix, f.word, a.FullStrip)
+	if !ok {
+		return out
+	}
+	p := a.prefixed(f, r)
+	p.word = w
+	if p.valid() {
+		out = append(out, Form{Word: w, Flags: joinFlags(keys, p.flags), Prefixed: true, Suffixed: p.suffixed})
+	}
+	for _, key := range r.Flags {
+		pre, ok := a.AffixMap[key]
+		if !ok || pre.Type != Prefix {
+			continue
+		}
+		for _, outer := range pre.Rules {
+			out = a.prefixForms(p, outer, keys, depth-1, out)
+		}
+	}
+	return out
+}
+
+// suffixForms applies the suffix flags in keys to the parent form,
+// then recursively the suffix flags of the rules' continuation classes
+// until depth suffixes are stacked
+func (a DictConfig) suffixForms(parent affixed, keys []rune, depth int, out []affixed) []affixed {
+	if depth == 0 {
+		return out
+	}
+	for _, key := range keys {
+		af, ok := a.AffixMap[key]
+		if !ok || af.Type != Suffix {
+			continue
+		}
+		for _, r := range af.Rules {
+			w, ok := r.apply(Suffix, parent.word, a.FullStrip)
+			if !ok {
+				continue
+			}
+			f := a.ruleState(r)
+			f.word = w
+			f.cross = parent.cross && af.CrossProduct
+			f.flags = joinFlags(parent.flags, r.Flags)
+			f.suffixed = true
+			f.circumfix = f.circumfix || parent.circumfix
+			out = append(out, f)
+			out = a.suffixForms(f, r.Flags, depth-1, out)
+		}
+	}
+	return out
+}
+
+// joinFlags concatenates two flag lists without touching the first
+func joinFlags(a, b []rune) []rune {
+	return append(a[:len(a):len(a)], b...)
+}
+
+// flagString encodes flags as comma separated numbers, which works
+// for every FLAG type, e.g. to store them in the database
+func flagString(flags []rune) string {
+	buf := make([]byte, 0, len(flags)*4)
+	for i, f := range flags {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendInt(buf, int64(f), 10)
+	}
+	return string(buf)
+}
+
+// parseFlagString decodes flags encoded by flagString
+func parseFlagString(s string) []rune {
+	if s == "" {
+		return nil
+	}
+	fields := strings.Split(s, ",")
+	flags := make([]rune, 0, len(fields))
+	for _, field := range fields {
+		if val, err := strconv.ParseInt(field, 10, 32); err == nil {
+			flags = append(flags, rune(val))
+		}
+	}
+	return flags
+}
+
+// Form is a word form generated from a dictionary line
+//
+//	Forms with the ONLYINCOMPOUND flag are only valid inside compounds
+type Form struct {
+	Word     string
+	Flags    []rune // flags of the stem and continuation classes of the applied affixes
+	Prefixed bool   // a prefix was applied, compounds only allow it on the first word
+	Suffixed bool   // a suffix was applied, compounds only allow it on the last word
+}
+
+// unique removes duplicated word forms keeping the first one
+func unique(forms []Form) []Form {
+	if len(forms) < 2 {
+		return forms
+	}
+	seen := make(map[string]struct{}, len(forms))
+	out := forms[:0]
+	for _, f := range forms {
+		if _, ok := seen[f.Word]; ok {
+			continue
+		}
+		seen[f.Word] = struct{}{}
+		out = append(out, f)
+	}
+	return out
+}
+
 // DictConfig is a partial representation of a Hunspell AFF (Affix) file.
 type DictConfig struct {
 	Flag              string            `json:"flag,omitempty"`
@@ -64,99 +294,335 @@ type DictConfig struct {
 	WordChars         string            `json:"word_chars,omitempty"`
 	NoSuggestFlag     rune              `json:"no_suggest_flag,omitempty"`
 	IconvReplacements []string          `json:"iconv_replacements,omitempty"`
+	OconvReplacements []string          `json:"oconv_replacements,omitempty"`
 	Replacements      [][2]string       `json:"replacements,omitempty"`
+	Maps              [][]string        `json:"maps,omitempty"`          // MAP, related characters or "(strings)"
+	KeyboardRows      []string          `json:"keyboard_rows,omitempty"` // KEY, rows of neighbour keys
+	Phone             [][2]string       `json:"phone,omitempty"`         // PHONE, see phonet
+	Lang              string            `json:"lang,omitempty"`          // LANG, or the name of the dictionary
 	AffixMap          map[rune]Affix    `json:"affix_map,omitempty"`
 	CamelCase         int               `json:"camel_case,omitempty"`
 	CompoundMin       int               `json:"compound_min,omitempty"`
-	CompoundOnly      string            `json:"compound_only,omitempty"`
+	CompoundOnlyFlag  rune              `json:"compound_only_flag,omitempty"` // ONLYINCOMPOUND
+	CompoundFlag      rune              `json:"compound_flag,omitempty"`
+	CompoundBegin     rune              `json:"compound_begin,omitempty"`
+	CompoundMiddle    rune              `json:"compound_middle,omitempty"`
+	CompoundEnd       rune              `json:"compound_end,omitempty"`
+	CompoundWordMax   int               `json:"compound_word_max,omitempty"` // 0 is unlimited
+	CheckCompoundDup  bool              `json:"check_compound_dup,omitempty"`
+	CheckCompoundCase bool              `json:"check_compound_case,omitempty"`
+	CheckCompoundRep  bool              `json:"check_compound_rep,omitempty"`
+	CompoundPatterns  []CompoundPattern `json:"compound_patterns,omitempty"`
+	NeedAffixFlag     rune              `json:"need_affix_flag,omitempty"`
+	CircumfixFlag     rune              `json:"circumfix_flag,omitempty"`
+	ForbiddenFlag     rune              `json:"forbidden_flag,omitempty"`
+	KeepCaseFlag      rune              `json:"keep_case_flag,omitempty"`
+	ForceUCaseFlag    rune              `json:"force_ucase_flag,omitempty"` // compounds ending with it must be capitalized
+	CheckSharps       bool              `json:"check_sharps,omitempty"`
+	Encoding          string            `json:"encoding,omitempty"` // SET, files are converted to UTF-8 when read
+	FullStrip         bool              `json:"full_strip,omitempty"`
+	Ignore            string            `json:"ignore,omitempty"` // IGNORE, characters removed from words and input
+	ComplexPrefixes   bool              `json:"complex_prefixes,omitempty"`
 	CompoundRule      []string          `json:"compound_rule,omitempty"`
-	CompoundMap       map[rune][]string `json:"compound_map,omitempty"`
+	Break             []string          `json:"break,omitempty"`         // BREAK, "^-" and "-$" are anchored
+	AffixAliases      []string          `json:"affix_aliases,omitempty"` // AF, "word/1" is AffixAliases[0]
+	MorphAliases      []string          `json:"morph_aliases,omitempty"` // AM, morphological field 1 is MorphAliases[0]
+
+	// Deprecated: CompoundOnly is the ONLYINCOMPOUND flag as written
+	// in the affix file, use CompoundOnlyFlag
+	CompoundOnly string `json:"compound_only,omitempty"`
+
+	// Deprecated: CompoundMap lists the words of the .dic file by
+	// COMPOUNDRULE flag, compounds are not checked with it
+	CompoundMap map[rune][]string `json:"compound_map,omitempty"`
 }
 
-// Expand expands a word/affix using dictionary/affix rules
-//
-//	This also supports CompoundRule flags
-func (a DictConfig) Expand(wordAffix string, out []string) ([]string, error) {
-	out = out[:0]
-	idx := strings.Index(wordAffix, "/")
+// Flag types as declared by the FLAG stanza
+const (
+	FlagASCII = "ASCII" // one character per flag (default)
+	FlagUTF8  = "UTF-8" // one UTF-8 character per flag
+	FlagLong  = "long"  // two characters per flag
+	FlagNum   = "num"   // comma separated decimal numbers
+)
 
-	// not found
-	if idx == -1 {
-		out = append(out, wordAffix)
+// ParseFlags splits a string of flags according to the FLAG stanza
+//
+//	Long flags are packed as the first character shifted by 8 bits
+//	plus the second, numeric flags are their value, as in Hunspell
+func (a DictConfig) ParseFlags(flags string) ([]rune, error) {
+	switch a.Flag {
+	case FlagLong:
+		if len(flags)%2 != 0 {
+			return nil, fmt.Errorf("long flags %q have odd length", flags)
+		}
+		out := make([]rune, 0, len(flags)/2)
+		for i := 0; i < len(flags); i += 2 {
+			out = append(out, rune(flags[i])<<8|rune(flags[i+1]))
+		}
 		return out, nil
+	case FlagNum:
+		parts := strings.Split(flags, ",")
+		out := make([]rune, 0, len(parts))
+		for _, part := range parts {
+			val, err := strconv.ParseUint(part, 10, 16)
+			if err != nil || val == 0 {
+				return nil, fmt.Errorf("numeric flag %q is not in 1-65535", part)
+			}
+			out = append(out, rune(val))
+		}
+		return out, nil
+	default:
+		return []rune(flags), nil
+	}
+}
+
+// parseFlag parses a stanza value that must contain exactly one flag
+func (a DictConfig) parseFlag(stanza, val string) (rune, error) {
+	flags, err := a.ParseFlags(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s stanza: %s", stanza, err)
+	}
+	if len(flags) != 1 {
+		return 0, fmt.Errorf("%s stanza had more than one flag: %q", stanza, val)
+	}
+	return flags[0], nil
+}
+
+// DecodeFlags parses the flags of a dictionary word or of an affix
+// continuation class, resolving AF aliases when the file has any
+func (a DictConfig) DecodeFlags(flags string) ([]rune, error) {
+	if len(a.AffixAliases) == 0 {
+		return a.ParseFlags(flags)
+	}
+	idx, err := strconv.Atoi(flags)
+	if err != nil || idx < 1 || idx > len(a.AffixAliases) {
+		return nil, fmt.Errorf("unknown AF alias %q, have %d", flags, len(a.AffixAliases))
 	}
-	if idx == 0 || idx+1 == len(wordAffix) {
-		return nil, fmt.Errorf("Slash char found in first or last position")
+	return a.ParseFlags(a.AffixAliases[idx-1])
+}
+
+// isMorphField returns true for Hunspell morphological fields
+// like "po:noun" or, if the file has AM aliases, their numbers
+func (a DictConfig) isMorphField(field string) bool {
+	if len(field) > 3 && field[2] == ':' {
+		return true
+	}
+	if len(a.MorphAliases) == 0 {
+		return false
+	}
+	_, err := strconv.Atoi(field)
+	return err == nil
+}
+
+// SplitMorph splits a dictionary line into the "word/flags" part
+// and its morphological fields, resolving AM aliases
+//
+//	Fields are separated from the word by a tab or by a space when
+//	they look like "xx:value".  Words may contain spaces, e.g. "Reino Unido"
+func (a DictConfig) SplitMorph(line string) (string, []string) {
+	wordAffix, rest := line, ""
+	if idx := strings.IndexByte(line, '\t'); idx != -1 {
+		wordAffix, rest = line[:idx], line[idx+1:]
+	} else {
+		fields := strings.Split(line, " ")
+		for i := 1; i < len(fields); i++ {
+			if a.isMorphField(fields[i]) {
+				wordAffix = strings.Join(fields[:i], " ")
+				rest = strings.Join(fields[i:], " ")
+				break
+			}
+		}
 	}
-	// safe
-	word, keyString := wordAffix[:idx], wordAffix[idx+1:]
 
-	// check to see if any of the flags are in the
-	// "compound only".  If so then nothing to add
-	compoundOnly := false
-	for _, key := range keyString {
-		if strings.IndexRune(a.CompoundOnly, key) != -1 {
-			compoundOnly = true
+	var morph []string
+	for _, field := range strings.Fields(rest) {
+		if idx, err := strconv.Atoi(field); err == nil && len(a.MorphAliases) > 0 {
+			if idx >= 1 && idx <= len(a.MorphAliases) {
+				morph = append(morph, strings.Fields(a.MorphAliases[idx-1])...)
+			}
 			continue
 		}
-		if _, ok := a.CompoundMap[key]; !ok {
-			// the isn't a compound flag
+		morph = append(morph, field)
+	}
+	return strings.TrimSpace(wordAffix), morph
+}
+
+// CompoundRuleFlags splits a COMPOUNDRULE into its elements
+//
+//	Flags are returned as-is and the "*" and "?" quantifiers as negative
+//	values (-'*' and -'?') so they never collide with a numeric flag.
+//	Long and numeric flags must be written in parentheses, e.g. "(aa)(bb)*"
+func (a DictConfig) CompoundRuleFlags(rule string) ([]rune, error) {
+	out := []rune{}
+	for len(rule) > 0 {
+		switch rule[0] {
+		case '*', '?':
+			out = append(out, -rune(rule[0]))
+			rule = rule[1:]
 			continue
+		case '(':
+			end := strings.IndexByte(rule, ')')
+			if end == -1 {
+				return nil, fmt.Errorf("COMPOUNDRULE %q has unbalanced parentheses", rule)
+			}
+			flag, err := a.parseFlag("COMPOUNDRULE", rule[1:end])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, flag)
+			rule = rule[end+1:]
+			continue
+		}
+		if a.Flag == FlagLong || a.Flag == FlagNum {
+			return nil, fmt.Errorf("COMPOUNDRULE %q: %s flags must be in parentheses", rule, a.Flag)
 		}
-		// is a compound flag
-		a.CompoundMap[key] = append(a.CompoundMap[key], word)
+		r, size := utf8.DecodeRuneInString(rule)
+		out = append(out, r)
+		rule = rule[size:]
 	}
+	return out, nil
+}
 
-	if compoundOnly {
-		return out, nil
+// Expand expands a word/affix using dictionary/affix rules
+func (a DictConfig) Expand(wordAffix string, out []string) ([]string, error) {
+	out = out[:0]
+	forms, err := a.ExpandForms(wordAffix, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range forms {
+		if !hasFlag(f.Flags, a.CompoundOnlyFlag) {
+			out = append(out, f.Word)
+		}
 	}
+	return out, nil
+}
 
-	out = append(out, word)
-	prefixes := make([]Affix, 0, 5)
-	suffixes := make([]Affix, 0, 5)
-	for _, key := range keyString {
-		// want keyString to []?something?
-		// then iterate over that
-		af, ok := a.AffixMap[key]
-		if !ok {
-			// is it compound flag?
-			if _, ok := a.CompoundMap[key]; ok {
-				continue
-			}
-			// is it a NoSuggest?
-			if key == a.NoSuggestFlag {
-				continue
-			}
-			// no idea
-			return nil, fmt.Errorf("unable to find affix key %v", key)
+// parseDicLine splits a .dic line into the word and its flags
+func (a DictConfig) parseDicLine(line string) (string, []rune, error) {
+	line, _ = a.SplitMorph(line)
+	idx := strings.Index(line, "/")
+
+	// not found
+	if idx == -1 {
+		return a.RemoveIgnored(line), nil, nil
+	}
+	if idx == 0 || idx+1 == len(line) {
+		return "", nil, fmt.Errorf("Slash char found in first or last position")
+	}
+	keys, err := a.DecodeFlags(line[idx+1:])
+	if err != nil {
+		return "", nil, err
+	}
+	word := a.RemoveIgnored(line[:idx])
+	for _, key := range keys {
+		if words, ok := a.CompoundMap[key]; ok {
+			a.CompoundMap[key] = append(words, word)
+		}
+	}
+	return word, keys, nil
+}
+
+// ExpandForms is Expand keeping the flags that apply to each word form
+func (a DictConfig) ExpandForms(wordAffix string, out []Form) ([]Form, error) {
+	word, keys, err := a.parseDicLine(wordAffix)
+	if err != nil {
+		return nil, err
+	}
+	return a.expandStem(word, keys, out), nil
+}
+
+// expandStem returns the word forms of a stem with the flags keys
+func (a DictConfig) expandStem(word string, keys []rune, out []Form) []Form {
+	out = out[:0]
+	if keys == nil {
+		return append(out, Form{Word: word})
+	}
+
+	// NEEDAFFIX stems are only valid with an affix
+	if !hasFlag(keys, a.NeedAffixFlag) {
+		out = append(out, Form{Word: word, Flags: keys})
+	}
+
+	// twofold suffixes, or twofold prefixes with COMPLEXPREFIXES
+	suffixDepth, prefixDepth := maxSuffixes, 1
+	if a.ComplexPrefixes {
+		suffixDepth, prefixDepth = 1, maxSuffixes
+	}
+
+	// suffixes, including the ones from continuation classes.  The
+	// bare stem has no affix to satisfy a NEEDAFFIX prefix
+	stem := affixed{word: word, cross: true, needAffix: true}
+	suffixed := a.suffixForms(stem, keys, suffixDepth, nil)
+	for _, f := range suffixed {
+		if f.valid() {
+			out = append(out, Form{Word: f.word, Flags: joinFlags(keys, f.flags), Suffixed: true})
 		}
-		if !af.CrossProduct {
-			out = af.Expand(word, out)
+	}
+
+	for _, key := range keys {
+		pre, ok := a.AffixMap[key]
+		if !ok || pre.Type != Prefix {
+			// suffix, compound, NoSuggest or any other flag that
+			// does not generate prefixed forms.  Hunspell silently
+			// ignores unknown flags as well
 			continue
 		}
-		if af.Type == Prefix {
-			prefixes = append(prefixes, af)
-		} else {
-			suffixes = append(suffixes, af)
+		for _, r := range pre.Rules {
+			out = a.prefixForms(stem, r, keys, prefixDepth, out)
+
+			// now do cross product
+			if pre.CrossProduct {
+				for _, f := range suffixed {
+					if f.cross {
+						out = a.prefixForms(f, r, keys, prefixDepth, out)
+					}
+				}
+			}
+
+			// suffixes allowed by the prefix continuation class
+			if w, ok := r.apply(Prefix, word, a.FullStrip); ok {
+				for _, f := range a.suffixForms(affixed{word: w, cross: true}, r.Flags, suffixDepth, nil) {
+					if p := a.prefixed(f, r); p.valid() {
+						out = append(out, Form{Word: f.word, Flags: joinFlags(keys, p.flags), Prefixed: true, Suffixed: true})
+					}
+				}
+			}
 		}
 	}
 
-	// expand all suffixes with out any prefixes
-	for _, suf := range suffixes {
-		out = suf.Expand(word, out)
+	// prefixes allowed by suffix continuation classes
+	for _, f := range suffixed {
+		for _, key := range f.flags {
+			pre, ok := a.AffixMap[key]
+			if !ok || pre.Type != Prefix {
+				continue
+			}
+			for _, r := range pre.Rules {
+				out = a.prefixForms(f, r, keys, prefixDepth, out)
+			}
+		}
 	}
-	for _, pre := range prefixes {
-		prewords := pre.Expand(word, nil)
-		out = append(out, prewords...)
+	return unique(out)
+}
 
-		// now do cross product
-		for _, suf := range suffixes {
-			for _, w := range prewords {
-				out = suf.Expand(w, out)
+// parseMap splits a MAP stanza into its related characters, strings
+// of several characters are in parentheses as in "ß(ss)"
+func parseMap(field string) []string {
+	var related []string
+	for len(field) > 0 {
+		if field[0] == '(' {
+			if end := strings.IndexByte(field, ')'); end > 1 {
+				related = append(related, field[1:end])
+				field = field[end+1:]
+				continue
 			}
 		}
+		_, size := utf8.DecodeRuneInString(field)
+		related = append(related, field[:size])
+		field = field[size:]
 	}
-	return out, nil
+	return related
 }
 
 func isCrossProduct(val string) (bool, error) {
@@ -170,14 +636,30 @@ func isCrossProduct(val string) (bool, error) {
 }
 
 // NewDictConfig reads an Hunspell AFF file
+//
+//	The file is converted to UTF-8 according to its SET stanza
 func NewDictConfig(file io.Reader) (*DictConfig, error) {
 	aff := DictConfig{
-		Flag:        "ASCII",
+		Flag:        FlagASCII,
 		AffixMap:    make(map[rune]Affix),
 		CompoundMap: make(map[rune][]string),
 		CompoundMin: 3, // default in Hunspell
 	}
-	scanner := bufio.NewScanner(file)
+
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimPrefix(raw, []byte(utf8BOM))
+	aff.Encoding = findSet(raw)
+	decoded, err := aff.DecodeReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	// the first AF and AM lines are counts, not aliases
+	afCount, amCount, mapCount := false, false, false
+	scanner := bufio.NewScanner(decoded)
 	for scanner.Scan() {
 		line := scanner.Text()
 		parts := strings.Fields(line)
@@ -187,6 +669,35 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 		switch parts[0] {
 		case "#":
 			continue
+		case "AF":
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("AF stanza had %d fields, expected 2", len(parts))
+			}
+			if !afCount {
+				afCount = true
+				val, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("AF stanza had %q expected number", parts[1])
+				}
+				aff.AffixAliases = make([]string, 0, val)
+				continue
+			}
+			// anything after the flags is a comment
+			aff.AffixAliases = append(aff.AffixAliases, parts[1])
+		case "AM":
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("AM stanza had %d fields, expected 2", len(parts))
+			}
+			if !amCount {
+				amCount = true
+				val, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("AM stanza had %q expected number", parts[1])
+				}
+				aff.MorphAliases = make([]string, 0, val)
+				continue
+			}
+			aff.MorphAliases = append(aff.MorphAliases, strings.Join(parts[1:], " "))
 		case "TRY":
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("TRY stanza had %d fields, expected 2", len(parts))
@@ -203,6 +714,17 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 			}
 			// we have 3
 			aff.IconvReplacements = append(aff.IconvReplacements, parts[1], parts[2])
+		case "OCONV":
+			// if only 2 fields, then its the first stanza that just provides a count
+			//  we don't care, as we dynamically allocate
+			if len(parts) == 2 {
+				continue
+			}
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("OCONV stanza had %d fields, expected 2", len(parts))
+			}
+			// we have 3
+			aff.OconvReplacements = append(aff.OconvReplacements, parts[1], parts[2])
 		case "REP":
 			// if only 2 fields, then its the first stanza that just provides a count
 			//  we don't care, as we dynamically allocate
@@ -214,6 +736,37 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 			}
 			// we have 3
 			aff.Replacements = append(aff.Replacements, [2]string{parts[1], parts[2]})
+		case "MAP":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("MAP stanza had %d fields, expected 2", len(parts))
+			}
+			// the first stanza is the count, as for AF
+			if !mapCount {
+				mapCount = true
+				if _, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+					continue
+				}
+			}
+			aff.Maps = append(aff.Maps, parseMap(parts[1]))
+		case "PHONE":
+			// the first stanza is the count, as for REP
+			if len(parts) == 2 {
+				continue
+			}
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("PHONE stanza had %d fields, expected 2", len(parts))
+			}
+			aff.Phone = append(aff.Phone, [2]string{parts[1], parts[2]})
+		case "LANG":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("LANG stanza had %d fields, expected 2", len(parts))
+			}
+			aff.Lang = parts[1]
+		case "KEY":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("KEY stanza had %d fields, expected 2", len(parts))
+			}
+			aff.KeyboardRows = strings.Split(parts[1], "|")
 		case "COMPOUNDMIN":
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("COMPOUNDMIN stanza had %d fields, expected 2", len(parts))
@@ -223,11 +776,126 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 				return nil, fmt.Errorf("COMPOUNDMIN stanza had %q expected number", parts[1])
 			}
 			aff.CompoundMin = int(val)
+		case "COMPOUNDFLAG", "COMPOUNDBEGIN", "COMPOUNDMIDDLE", "COMPOUNDEND", "COMPOUNDLAST":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("%s stanza had %d fields, expected 2", parts[0], len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			switch parts[0] {
+			case "COMPOUNDFLAG":
+				aff.CompoundFlag = flag
+			case "COMPOUNDBEGIN":
+				aff.CompoundBegin = flag
+			case "COMPOUNDMIDDLE":
+				aff.CompoundMiddle = flag
+			default:
+				// COMPOUNDLAST is the old name of COMPOUNDEND
+				aff.CompoundEnd = flag
+			}
+		case "BREAK":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("BREAK stanza had %d fields, expected 2", len(parts))
+			}
+			// first stanza provides a count, "BREAK 0" disables breaking
+			if val, err := strconv.ParseInt(parts[1], 10, 64); err == nil && aff.Break == nil {
+				aff.Break = make([]string, 0, val)
+				continue
+			}
+			aff.Break = append(aff.Break, parts[1])
+		case "COMPOUNDWORDMAX":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("COMPOUNDWORDMAX stanza had %d fields, expected 2", len(parts))
+			}
+			val, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("COMPOUNDWORDMAX stanza had %q expected number", parts[1])
+			}
+			aff.CompoundWordMax = int(val)
+		case "CHECKCOMPOUNDDUP":
+			aff.CheckCompoundDup = true
+		case "CHECKCOMPOUNDCASE":
+			aff.CheckCompoundCase = true
+		case "CHECKCOMPOUNDREP":
+			aff.CheckCompoundRep = true
+		case "CHECKCOMPOUNDPATTERN":
+			// first stanza just provides a count
+			if len(parts) == 2 {
+				continue
+			}
+			pattern, err := aff.parseCompoundPattern(parts[1:])
+			if err != nil {
+				return nil, err
+			}
+			aff.CompoundPatterns = append(aff.CompoundPatterns, pattern)
 		case "ONLYINCOMPOUND":
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("ONLYINCOMPOUND stanza had %d fields, expected 2", len(parts))
 			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.CompoundOnlyFlag = flag
 			aff.CompoundOnly = parts[1]
+		case "NEEDAFFIX", "PSEUDOROOT":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("%s stanza had %d fields, expected 2", parts[0], len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.NeedAffixFlag = flag
+		case "FORBIDDENWORD":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("FORBIDDENWORD stanza had %d fields, expected 2", len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.ForbiddenFlag = flag
+		case "KEEPCASE":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("KEEPCASE stanza had %d fields, expected 2", len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.KeepCaseFlag = flag
+		case "FORCEUCASE":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("FORCEUCASE stanza had %d fields, expected 2", len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.ForceUCaseFlag = flag
+		case "CHECKSHARPS":
+			aff.CheckSharps = true
+		case "FULLSTRIP":
+			aff.FullStrip = true
+		case "COMPLEXPREFIXES":
+			aff.ComplexPrefixes = true
+		case "IGNORE":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("IGNORE stanza had %d fields, expected 2", len(parts))
+			}
+			aff.Ignore = parts[1]
+		case "CIRCUMFIX":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("CIRCUMFIX stanza had %d fields, expected 2", len(parts))
+			}
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			aff.CircumfixFlag = flag
 		case "COMPOUNDRULE":
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("COMPOUNDRULE stanza had %d fields, expected 2", len(parts))
@@ -236,10 +904,14 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 			if err == nil {
 				aff.CompoundRule = make([]string, 0, val)
 			} else {
+				flags, err := aff.CompoundRuleFlags(parts[1])
+				if err != nil {
+					return nil, err
+				}
 				aff.CompoundRule = append(aff.CompoundRule, parts[1])
-				for _, char := range parts[1] {
-					if _, ok := aff.CompoundMap[char]; !ok {
-						aff.CompoundMap[char] = []string{}
+				for _, flag := range flags {
+					if _, ok := aff.CompoundMap[flag]; !ok && flag > 0 {
+						aff.CompoundMap[flag] = []string{}
 					}
 				}
 			}
@@ -247,12 +919,11 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("NOSUGGEST stanza had %d fields, expected 2", len(parts))
 			}
-			// should use runes or parse correctly
-			chars := []rune(parts[1])
-			if len(chars) != 1 {
-				return nil, fmt.Errorf("NOSUGGEST stanza had more than one flag: %q", parts[1])
+			flag, err := aff.parseFlag(parts[0], parts[1])
+			if err != nil {
+				return nil, err
 			}
-			aff.NoSuggestFlag = chars[0]
+			aff.NoSuggestFlag = flag
 		case "WORDCHARS":
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("WORDCHAR stanza had %d fields, expected 2", len(parts))
@@ -262,16 +933,20 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 			if len(parts) != 2 {
 				return nil, fmt.Errorf("FLAG stanza had %d, expected 1", len(parts))
 			}
-			aff.Flag = parts[1]
-			return nil, fmt.Errorf("FLAG stanza not yet supported")
+			switch parts[1] {
+			case FlagLong, FlagNum, FlagUTF8:
+				aff.Flag = parts[1]
+			default:
+				return nil, fmt.Errorf("FLAG stanza has unknown type %q", parts[1])
+			}
 		case "PFX", "SFX":
 			atype := Prefix
 			if parts[0] == "SFX" {
 				atype = Suffix
 			}
 
-			switch len(parts) {
-			case 4:
+			switch {
+			case len(parts) == 4:
 				cross, err := isCrossProduct(parts[2])
 				if err != nil {
 					return nil, err
@@ -281,11 +956,18 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 					Type:         atype,
 					CrossProduct: cross,
 				}
-				flag := rune(parts[1][0])
+				flag, err := aff.parseFlag(parts[0], parts[1])
+				if err != nil {
+					return nil, err
+				}
 				aff.AffixMap[flag] = a
-			case 5:
+			case len(parts) >= 5:
+				// fields after the condition are morphological
 				// does this need to be split out into suffix and prefix?
-				flag := rune(parts[1][0])
+				flag, err := aff.parseFlag(parts[0], parts[1])
+				if err != nil {
+					return nil, err
+				}
 				a, ok := aff.AffixMap[flag]
 				if !ok {
 					return nil, fmt.Errorf("Got rules for flag %q but no definition", flag)
@@ -296,24 +978,28 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 					strip = parts[2]
 				}
 
-				var matcher *regexp.Regexp
-				var err error
-				pat := parts[4]
-				if pat != "." {
-					if a.Type == Prefix {
-						pat = "^" + pat
-					} else {
-						pat = pat + "$"
-					}
-					matcher, err = regexp.Compile(pat)
+				matcher, err := conditionRegexp(parts[4], a.Type)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to compile %s: %s", parts[4], err)
+				}
+
+				// "0" is an empty affix, "s/AB" carries a continuation class
+				affixText, contFlags := parts[3], []rune(nil)
+				if idx := strings.IndexByte(affixText, '/'); idx != -1 {
+					contFlags, err = aff.DecodeFlags(affixText[idx+1:])
 					if err != nil {
-						return nil, fmt.Errorf("Unable to compile %s", pat)
+						return nil, fmt.Errorf("%s continuation class: %s", parts[0], err)
 					}
+					affixText = affixText[:idx]
+				}
+				if affixText == "0" {
+					affixText = ""
 				}
 
 				a.Rules = append(a.Rules, Rule{
 					Strip:     strip,
-					AffixText: parts[3],
+					AffixText: affixText,
+					Flags:     contFlags,
 					Pattern:   parts[4],
 					matcher:   matcher,
 				})
@@ -330,5 +1016,58 @@ func NewDictConfig(file io.Reader) (*DictConfig, error) {
 		return nil, err
 	}
 
+	// Hunspell breaks at hyphens by default
+	if aff.Break == nil {
+		aff.Break = []string{"-", "^-", "-$"}
+	}
+
+	// IGNORE applies to affixes as well, whatever the stanza order
+	if aff.Ignore != "" {
+		for flag, a := range aff.AffixMap {
+			for i, r := range a.Rules {
+				a.Rules[i].Strip = aff.RemoveIgnored(r.Strip)
+				a.Rules[i].AffixText = aff.RemoveIgnored(r.AffixText)
+			}
+			aff.AffixMap[flag] = a
+		}
+	}
+
 	return &aff, nil
 }
+
+// RemoveIgnored removes the IGNORE characters from a word
+func (a DictConfig) RemoveIgnored(word string) string {
+	if a.Ignore == "" {
+		return word
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(a.Ignore, r) {
+			return -1
+		}
+		return r
+	}, word)
+}
+
+// compileConditions compiles the conditions of the affix rules,
+// which are lost when the config is read back from JSON
+func (a *DictConfig) compileConditions() error {
+	// the rules share few conditions, 242 for the 5421 French ones
+	matchers := make(map[string]*regexp.Regexp)
+	for flag, af := range a.AffixMap {
+		for i, r := range af.Rules {
+			key := string(rune(af.Type)) + r.Pattern
+			matcher, ok := matchers[key]
+			if !ok {
+				var err error
+				matcher, err = conditionRegexp(r.Pattern, af.Type)
+				if err != nil {
+					return fmt.Errorf("Unable to compile %s: %s", r.Pattern, err)
+				}
+				matchers[key] = matcher
+			}
+			af.Rules[i].matcher = matcher
+		}
+		a.AffixMap[flag] = af
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package gospell
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -11,6 +13,8 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"github.com/vbatushev/gospell/plaintext"
 )
 
 // SmokeTest for AFF parser.  Contains a little bit of everything.
@@ -148,6 +152,358 @@ SFX B y ied y
 	}
 }
 
+func TestParseFlags(t *testing.T) {
+	cases := []struct {
+		flag  string
+		flags string
+		want  []rune
+	}{
+		{FlagASCII, "AB", []rune{'A', 'B'}},
+		{FlagUTF8, "añ", []rune{'a', 'ñ'}},
+		{FlagLong, "L'D'", []rune{'L'<<8 | '\'', 'D'<<8 | '\''}},
+		{FlagNum, "1,302,65535", []rune{1, 302, 65535}},
+	}
+	for pos, tt := range cases {
+		aff := DictConfig{Flag: tt.flag}
+		got, err := aff.ParseFlags(tt.flags)
+		if err != nil {
+			t.Errorf("%d: unable to parse %q: %s", pos, tt.flags, err)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%d: flags %q want %v got %v", pos, tt.flags, tt.want, got)
+		}
+	}
+
+	bad := []struct {
+		flag  string
+		flags string
+	}{
+		{FlagLong, "ABC"},
+		{FlagNum, "1,a"},
+		{FlagNum, "0"},
+		{FlagNum, "65536"},
+	}
+	for pos, tt := range bad {
+		aff := DictConfig{Flag: tt.flag}
+		if _, err := aff.ParseFlags(tt.flags); err == nil {
+			t.Errorf("%d: expected error for %s flags %q", pos, tt.flag, tt.flags)
+		}
+	}
+}
+
+func TestExpandFlagTypes(t *testing.T) {
+	samples := []struct {
+		aff  string
+		word string
+	}{
+		{`
+FLAG long
+PFX Aa Y 1
+PFX Aa 0 re .
+SFX Bb Y 2
+SFX Bb 0 ed [^y]
+SFX Bb y ied y
+`, "work/AaBb"},
+		{`
+FLAG num
+PFX 1 Y 1
+PFX 1 0 re .
+SFX 302 Y 2
+SFX 302 0 ed [^y]
+SFX 302 y ied y
+`, "work/1,302"},
+		{`
+FLAG UTF-8
+PFX Á Y 1
+PFX Á 0 re .
+SFX ñ Y 2
+SFX ñ 0 ed [^y]
+SFX ñ y ied y
+`, "work/Áñ"},
+	}
+	want := []string{"work", "worked", "rework", "reworked"}
+	for pos, tt := range samples {
+		aff, err := NewDictConfig(strings.NewReader(tt.aff))
+		if err != nil {
+			t.Fatalf("%d: unable to parse sample: %s", pos, err)
+		}
+		got, err := aff.Expand(tt.word, nil)
+		if err != nil {
+			t.Errorf("%d: affix expansions error: %s", pos, err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%d: affix expansion want %v got %v", pos, want, got)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	sample := `
+AF 2
+AF AB # 1
+AF A  # 2
+AM 2
+AM is:plural
+AM po:noun is:singular
+
+SFX A Y 1
+SFX A 0 x . 1
+
+SFX B Y 1
+SFX B 0 y . 1
+`
+	aff, err := NewDictConfig(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Unable to parse sample: %s", err)
+	}
+	if !reflect.DeepEqual(aff.AffixAliases, []string{"AB", "A"}) {
+		t.Errorf("AF aliases are %v", aff.AffixAliases)
+	}
+	if len(aff.MorphAliases) != 2 || aff.MorphAliases[1] != "po:noun is:singular" {
+		t.Errorf("AM aliases are %v", aff.MorphAliases)
+	}
+
+	cases := []struct {
+		line  string
+		want  []string
+		morph []string
+	}{
+		{"foo/1", []string{"foo", "foox", "fooy"}, nil},
+		{"foo/2 2", []string{"foo", "foox"}, []string{"po:noun", "is:singular"}},
+		{"foo/2\tst:bar 1", []string{"foo", "foox"}, []string{"st:bar", "is:plural"}},
+		{"foo bar", []string{"foo bar"}, nil},
+	}
+	for pos, tt := range cases {
+		got, err := aff.Expand(tt.line, nil)
+		if err != nil {
+			t.Errorf("%d: affix expansions error: %s", pos, err)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%d: affix expansion want %v got %v", pos, tt.want, got)
+		}
+		if _, morph := aff.SplitMorph(tt.line); !reflect.DeepEqual(tt.morph, morph) {
+			t.Errorf("%d: morphology want %v got %v", pos, tt.morph, morph)
+		}
+	}
+
+	if _, err := aff.Expand("foo/3", nil); err == nil {
+		t.Errorf("Expected error for unknown AF alias")
+	}
+}
+
+func TestContinuationClasses(t *testing.T) {
+	sample := `
+PFX P Y 1
+PFX P 0 un/S .
+
+PFX Q Y 1
+PFX Q 0 re .
+
+SFX X Y 1
+SFX X 0 able/YQ .
+
+SFX Y Y 1
+SFX Y 0 s/Z .
+
+SFX Z Y 1
+SFX Z 0 es .
+
+SFX S N 1
+SFX S 0 ed .
+`
+	aff, err := NewDictConfig(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Unable to parse sample: %s", err)
+	}
+	rule := aff.AffixMap['X'].Rules[0]
+	if rule.AffixText != "able" || !reflect.DeepEqual(rule.Flags, []rune{'Y', 'Q'}) {
+		t.Errorf("Continuation class not parsed: %+v", rule)
+	}
+
+	cases := []struct {
+		word string
+		want []string
+	}{
+		// twofold suffix, the third level is not applied
+		{"drink/X", []string{"drink", "drinkable", "drinkables", "redrinkable", "redrinkables"}},
+		// prefix continuation allows a suffix the stem does not have
+		{"lock/P", []string{"lock", "unlock", "unlocked"}},
+	}
+	for pos, tt := range cases {
+		got, err := aff.Expand(tt.word, nil)
+		if err != nil {
+			t.Errorf("%d: affix expansions error: %s", pos, err)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%d: affix expansion want %v got %v", pos, tt.want, got)
+		}
+	}
+}
+
+func TestAffixRestrictions(t *testing.T) {
+	// circumfix sample is from the Hunspell test suite
+	sample := `
+NEEDAFFIX X
+CIRCUMFIX Y
+ONLYINCOMPOUND O
+
+PFX A Y 1
+PFX A 0 leg/Y .
+
+PFX B Y 1
+PFX B 0 legesleg/Y .
+
+SFX C Y 3
+SFX C 0 obb . +COMPARATIVE
+SFX C 0 obb/AY . +SUPERLATIVE
+SFX C 0 obb/BY . +SUPERSUPERLATIVE
+
+PFX P Y 1
+PFX P 0 re .
+
+SFX S Y 1
+SFX S 0 s .
+
+SFX I Y 1
+SFX I 0 ing/XS .
+
+SFX E Y 1
+SFX E 0 en/O .
+`
+	aff, err := NewDictConfig(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Unable to parse sample: %s", err)
+	}
+	if aff.NeedAffixFlag != 'X' || aff.CircumfixFlag != 'Y' || aff.CompoundOnlyFlag != 'O' {
+		t.Errorf("Flags not parsed: %+v", aff)
+	}
+
+	cases := []struct {
+		word string
+		want []string
+	}{
+		{"nagy/C", []string{"nagy", "nagyobb", "legnagyobb", "legeslegnagyobb"}},
+		{"walk/XPS", []string{"walks", "rewalk", "rewalks"}},
+		{"work/I", []string{"work", "workings"}},
+		{"haus/E", []string{"haus"}},
+		{"haus/O", nil},
+	}
+	for pos, tt := range cases {
+		got, err := aff.Expand(tt.word, nil)
+		if err != nil {
+			t.Errorf("%d: affix expansions error: %s", pos, err)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%d: affix expansion want %v got %v", pos, tt.want, got)
+		}
+	}
+}
+
+func TestSampleDictionaries(t *testing.T) {
+	cases := []struct {
+		lang  string
+		word  string
+		spell bool
+	}{
+		{"es_ES", "casas", true},
+		{"es_ES", "niños", true},
+		{"es_ES", "aisladoz", false},
+		{"fr", "maison", true},
+		{"fr", "maisons", true},
+		{"fr", "l'homme", true},
+		{"fr", "1er", true},
+		{"fr", "maisonz", false},
+		{"fr", "Bq", true},
+		{"fr", "BQ", false},
+		{"en_GB", "colour", true},
+		{"en_GB", "abandonware", true},
+		{"en_GB", "121st", true},
+		{"en_GB", "1012th", true},
+		{"en_GB", "121th", false},
+		{"en_GB", "e-mail-based", true},
+		{"en_GB", "colour-basde", false},
+	}
+	dicts := map[string]*GoSpell{}
+	for pos, tt := range cases {
+		gs, ok := dicts[tt.lang]
+		if !ok {
+			var err error
+			gs, err = NewGoSpell("./sample/"+tt.lang+".aff", "./sample/"+tt.lang+".dic")
+			if err != nil {
+				t.Fatalf("Unable to load %s: %s", tt.lang, err)
+			}
+			dicts[tt.lang] = gs
+		}
+		if gs.Spell(tt.word) != tt.spell {
+			t.Errorf("%d %s %q was not %v", pos, tt.lang, tt.word, tt.spell)
+		}
+	}
+}
+
+// TestConformance checks the fixtures in testdata, laid out as in the
+// Hunspell test suite: NAME.aff and NAME.dic with the words in NAME.good
+// accepted and the ones in NAME.wrong rejected
+func TestConformance(t *testing.T) {
+	affFiles, err := filepath.Glob("testdata/*.aff")
+	if err != nil || len(affFiles) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+	for _, affFile := range affFiles {
+		name := strings.TrimSuffix(affFile, ".aff")
+		gs, err := NewGoSpell(affFile, name+".dic")
+		if err != nil {
+			t.Errorf("%s: unable to create GoSpell: %s", name, err)
+			continue
+		}
+		for _, want := range []bool{true, false} {
+			ext := ".good"
+			if !want {
+				ext = ".wrong"
+			}
+			raw, err := os.ReadFile(name + ext)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			for _, word := range strings.Fields(string(raw)) {
+				if gs.Spell(word) != want {
+					t.Errorf("%s: %q was not %v", name, word, want)
+				}
+			}
+		}
+	}
+}
+
+// TestBreakLimit checks that words of many break points are rejected
+// without trying every way of breaking them
+func TestBreakLimit(t *testing.T) {
+	gs, err := NewGoSpell("testdata/break.aff", "testdata/break.dic")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	cases := []struct {
+		word  string
+		spell bool
+	}{
+		{strings.Repeat("foo-", 9) + "bar", true},
+		{strings.Repeat("foo-", 10) + "bar", false},
+		{strings.Repeat("foo-", 8) + "zq", false},
+		{strings.Repeat("foo-", 18) + "zq", false},
+		{strings.Repeat("foo-bar–", 40) + "zq", false},
+	}
+	for pos, tt := range cases {
+		start := time.Now()
+		if gs.Spell(tt.word) != tt.spell {
+			t.Errorf("%d %q was not %v", pos, tt.word, tt.spell)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%d %q took %s", pos, tt.word, elapsed)
+		}
+	}
+}
+
 func TestCompound(t *testing.T) {
 	sampleAff := `
 SET UTF-8
@@ -183,13 +539,6 @@ WORDCHARS 0123456789
 9/nm
 9th/pt
 `
-	aff := strings.NewReader(sampleAff)
-	dic := strings.NewReader(sampleDic)
-	gs, err := NewGoSpellReader(aff, dic, nil, "")
-	if err != nil {
-		t.Fatalf("Unable to create GoSpell: %s", err)
-	}
-
 	cases := []struct {
 		word  string
 		spell bool
@@ -240,9 +589,30 @@ WORDCHARS 0123456789
 		{"111st", false},
 		{"111th", true},
 	}
-	for pos, tt := range cases {
-		if gs.Spell(tt.word) != tt.spell {
-			t.Errorf("%d %q was not %v", pos, tt.word, tt.spell)
+
+	// both in memory and database word forms
+	for _, db := range []*gorm.DB{nil, createTable(t.TempDir()+"/compound.db", true, nil)} {
+		aff := strings.NewReader(sampleAff)
+		dic := strings.NewReader(sampleDic)
+		gs, err := NewGoSpellReader(aff, dic, db, "")
+		if err != nil {
+			t.Fatalf("Unable to create GoSpell: %s", err)
+		}
+		for pos, tt := range cases {
+			if gs.Spell(tt.word) != tt.spell {
+				t.Errorf("%d %q was not %v (db %v)", pos, tt.word, tt.spell, db != nil)
+			}
+		}
+		if got, want := gs.Decompose("121st"), []string{"1", "2", "1st"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Decompose want %v got %v (db %v)", want, got, db != nil)
+		}
+
+		// deprecated fields, as filled before the rules were compiled
+		if gs.Config.CompoundOnly != "c" {
+			t.Errorf("CompoundOnly want %q got %q (db %v)", "c", gs.Config.CompoundOnly, db != nil)
+		}
+		if got, want := gs.Config.CompoundMap['1'], []string{"1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("CompoundMap want %v got %v (db %v)", want, got, db != nil)
 		}
 	}
 }
@@ -299,6 +669,145 @@ GB
 	}
 }
 
+func TestForbidden(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+FORBIDDENWORD F
+
+SFX S Y 1
+SFX S 0 s .
+
+SFX X Y 1
+SFX X 0 x/F .
+`
+
+	sampleDic := `5
+foo/S
+foos/F
+bar/X
+GB
+MB
+`
+	aff := strings.NewReader(sampleAff)
+	dic := strings.NewReader(sampleDic)
+	gs, err := NewGoSpellReader(aff, dic, nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	_, err = gs.AddWordList(strings.NewReader("baz\n*MB\n*Acme\n"))
+	if err != nil {
+		t.Fatalf("Unable to add word list: %s", err)
+	}
+
+	cases := []struct {
+		word  string
+		spell bool
+	}{
+		{"foo", true},
+		{"foos", false},
+		{"Foos", false},
+		{"bar", true},
+		{"barx", false},
+		{"baz", true},
+		{"100GB", true},
+		{"MB", false},
+		{"100MB", false},
+		{"Acme", false},
+		{"ACME", false},
+		{"myAcme", false},
+		{"myBaz", true},
+	}
+	for pos, tt := range cases {
+		if gs.Spell(tt.word) != tt.spell {
+			t.Errorf("%d %q was not %v", pos, tt.word, tt.spell)
+		}
+	}
+}
+
+func TestCaseRules(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+KEEPCASE K
+CHECKSHARPS
+`
+
+	sampleDic := `5
+Bq/K
+mA/K
+straße
+Maß/K
+iPhone
+`
+	cases := []struct {
+		word  string
+		spell bool
+	}{
+		{"Bq", true},
+		{"BQ", false},
+		{"bq", false},
+		{"mA", true},
+		{"MA", false},
+		{"straße", true},
+		{"Straße", true},
+		{"STRASSE", true},
+		{"Maß", true},
+		{"MASS", true},
+		{"iPhone", true},
+		{"IPHONE", true},
+		{"iphone", false},
+	}
+
+	// both in memory and database word forms
+	for _, db := range []*gorm.DB{nil, createTable(t.TempDir()+"/case.db", true, nil)} {
+		aff := strings.NewReader(sampleAff)
+		dic := strings.NewReader(sampleDic)
+		gs, err := NewGoSpellReader(aff, dic, db, "xx")
+		if err != nil {
+			t.Fatalf("Unable to create GoSpell: %s", err)
+		}
+		for pos, tt := range cases {
+			if gs.Spell(tt.word) != tt.spell {
+				t.Errorf("%d %q was not %v (db %v)", pos, tt.word, tt.spell, db != nil)
+			}
+		}
+	}
+}
+
+func TestOutputConversion(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+WORDCHARS '’
+ICONV 1
+ICONV ’ '
+OCONV 1
+OCONV ' ’
+`
+	sampleDic := `2
+l'homme
+maison
+`
+	db := createTable(t.TempDir()+"/oconv.db", true, nil)
+	gs, err := NewGoSpellReader(strings.NewReader(sampleAff), strings.NewReader(sampleDic), db, "fr")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+
+	if got := gs.OutputConversion("l'homme"); got != "l’homme" {
+		t.Errorf("OutputConversion want %q got %q", "l’homme", got)
+	}
+
+	suggs := gs.GetSuggestions("lxhomme")
+	if !reflect.DeepEqual(suggs, []string{"l’homme"}) {
+		t.Errorf("Suggestions want [l’homme] got %v", suggs)
+	}
+
+	pt, _ := plaintext.NewIdentity()
+	diffs := SpellFile(gs, pt, []byte("l’homne maison"))
+	if len(diffs) != 1 || diffs[0].Original != "l’homne" {
+		t.Errorf("Reported words want [l’homne] got %+v", diffs)
+	}
+}
+
 func TestWorkWithDBForce(t *testing.T) {
 	correctWord := "ЧК"
 	wrongWord := "Чк"
@@ -347,3 +856,72 @@ func TestWorkWithDB(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// TestSpellStems checks that the stems lookup accepts the same word
+// forms as the full expansion
+func TestSpellStems(t *testing.T) {
+	sample := `
+NEEDAFFIX X
+CIRCUMFIX Y
+FULLSTRIP
+
+PFX A Y 1
+PFX A 0 leg/Y .
+
+SFX C Y 2
+SFX C 0 obb . +COMPARATIVE
+SFX C 0 obb/AY . +SUPERLATIVE
+
+PFX P Y 1
+PFX P 0 un/S .
+
+PFX Q Y 1
+PFX Q 0 re .
+
+SFX X Y 1
+SFX X 0 able/YQ .
+
+SFX S N 1
+SFX S 0 ed .
+
+SFX T Y 2
+SFX T y ies [^aeiou]y
+SFX T 0 s [aeiou]y
+
+SFX I Y 1
+SFX I 0 ing/XS .
+`
+	dic := `7
+nagy/C
+drink/X
+lock/P
+fly/T
+Play/T
+work/I
+walk/XS
+`
+	gs, err := NewGoSpellReader(strings.NewReader(sample), strings.NewReader(dic), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	if len(gs.Dict) != 0 {
+		t.Errorf("Word forms expanded: %v", gs.Dict)
+	}
+	lines := strings.Split(strings.TrimSpace(dic), "\n")[1:]
+	for _, line := range lines {
+		words, err := gs.Config.Expand(line, nil)
+		if err != nil {
+			t.Fatalf("%s: affix expansions error: %s", line, err)
+		}
+		for _, word := range words {
+			if !gs.Spell(word) {
+				t.Errorf("%s: %q not accepted", line, word)
+			}
+		}
+	}
+	for _, word := range []string{"walk", "nagyobbobb", "legnagy", "redrink", "unlocks", "flys", "plaies", "plays"} {
+		if gs.Spell(word) {
+			t.Errorf("%q accepted", word)
+		}
+	}
+}
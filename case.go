@@ -3,6 +3,7 @@ package gospell
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // WordCase is an enum of various word casing styles
@@ -60,6 +61,18 @@ func CaseStyle(word string) WordCase {
 	}
 }
 
+// toTitle upcases the first letter of a word leaving the rest as is
+//
+//	strings.Title treats an apostrophe as a word break and
+//	would return "That'S"
+func toTitle(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if size == 0 {
+		return word
+	}
+	return string(unicode.ToTitle(r)) + word[size:]
+}
+
 // CaseVariations returns
 // If AllUpper or First-Letter-Only is upcased: add the all upper case version
 // If AllLower, add the original, the title and upcase forms
@@ -67,16 +80,52 @@ func CaseStyle(word string) WordCase {
 func CaseVariations(word string, style WordCase) []string {
 	switch style {
 	case Title:
-		return []string{strings.Title(word), strings.ToUpper(word)}
+		return []string{toTitle(word), strings.ToUpper(word)}
 	// case AllLower:
 	// 	return []string{strings.ToLower(word), strings.ToTitle(word), strings.ToUpper(word)}
 	// return []string{word, strings.ToUpper(word[0:1]) + word[1:], strings.ToUpper(word)}
 	case AllUpper:
 		return []string{strings.ToUpper(word)}
+	case Mixed:
+		return []string{word, strings.ToUpper(word)}
 	// case Title:
 	// 	return []string{strings.ToTitle(word)}
 	default:
-		return []string{strings.ToLower(word), strings.Title(word), strings.ToUpper(word)}
+		return []string{strings.ToLower(word), toTitle(word), strings.ToUpper(word)}
 		// return []string{word, strings.ToUpper(word)}
 	}
 }
+
+// applyCase returns a lower case word in the given case style,
+// Mixed words can not be restored and are returned as is
+func applyCase(word string, style WordCase) string {
+	switch style {
+	case Title:
+		return toTitle(word)
+	case AllUpper:
+		return strings.ToUpper(word)
+	default:
+		return word
+	}
+}
+
+// CaseVariations returns the valid spellings of a dictionary word like
+// the CaseVariations function, honouring the KEEPCASE flag and, with
+// CHECKSHARPS, the German sharp s that is uppercased as "SS"
+//
+//	KEEPCASE words are only valid as written, but with CHECKSHARPS
+//	words with ß may be capitalized and uppercased with "SS" only
+func (a DictConfig) CaseVariations(word string, keepCase bool) []string {
+	sharps := a.CheckSharps && strings.ContainsRune(word, 'ß')
+	if keepCase {
+		if !sharps {
+			return []string{word}
+		}
+		return []string{word, toTitle(word), strings.ReplaceAll(strings.ToUpper(word), "ß", "SS")}
+	}
+	out := CaseVariations(word, CaseStyle(word))
+	if sharps {
+		out = append(out, strings.ReplaceAll(strings.ToUpper(word), "ß", "SS"))
+	}
+	return out
+}
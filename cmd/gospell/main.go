@@ -51,6 +51,19 @@ func main() {
 	flag.Parse()
 	args := flag.Args()
 
+	// "gospell compile [file]" writes the dictionary in the compiled
+	// format, by default to dictionary.gsc next to its .aff and .dic
+	// files.  The personal wordlist is not compiled, it is added to
+	// the dictionary loaded at each run
+	compile := len(args) > 0 && args[0] == "compile"
+	compiledFile := ""
+	if compile {
+		if len(args) > 1 {
+			compiledFile = args[1]
+		}
+		args = nil
+	}
+
 	if *listOnly {
 		defaultLog = defaultWord
 	}
@@ -69,33 +82,71 @@ func main() {
 
 	affFile := ""
 	dicFile := ""
+	gscFile := ""
 	for _, base := range filepath.SplitList(*dictPath) {
 		affFile = filepath.Join(base, *dicts+".aff")
 		dicFile = filepath.Join(base, *dicts+".dic")
+		gscFile = filepath.Join(base, *dicts+".gsc")
 		//log.Printf("Trying %s", affFile)
-		_, err1 := os.Stat(affFile)
-		_, err2 := os.Stat(dicFile)
+		aff, err1 := os.Stat(affFile)
+		dic, err2 := os.Stat(dicFile)
+		gsc, err3 := os.Stat(gscFile)
 		if err1 == nil && err2 == nil {
+			if compile && compiledFile == "" {
+				compiledFile = gscFile
+			}
+			// compiled dictionaries load faster, unless the .aff or
+			// .dic file changed since
+			if compile || err3 != nil || gsc.ModTime().Before(aff.ModTime()) || gsc.ModTime().Before(dic.ModTime()) {
+				gscFile = ""
+			}
 			break
 		}
 		affFile = ""
 		dicFile = ""
+		// or a compiled dictionary alone
+		if err3 == nil && !compile {
+			break
+		}
+		gscFile = ""
 	}
 
-	if affFile == "" {
+	if affFile == "" && gscFile == "" {
 		log.Fatalf("Unable to load %s", *dicts)
 	}
 
-	log.Printf("Loading %s %s", affFile, dicFile)
+	var h *gospell.GoSpell
+	var err error
 	timeStart := time.Now()
-	h, err := gospell.NewGoSpell(affFile, dicFile)
+	if gscFile != "" {
+		log.Printf("Loading %s", gscFile)
+		h, err = gospell.NewGoSpellCompiled(gscFile)
+	} else {
+		log.Printf("Loading %s %s", affFile, dicFile)
+		h, err = gospell.NewGoSpell(affFile, dicFile)
+	}
 	timeEnd := time.Now()
 
-	// note: 10x too slow
 	log.Printf("Loaded in %v", timeEnd.Sub(timeStart))
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
+	defer h.Close()
+
+	if compile {
+		fd, err := os.Create(compiledFile)
+		if err != nil {
+			log.Fatalf("Unable to create %s: %s", compiledFile, err)
+		}
+		if err := h.Compile(fd); err != nil {
+			log.Fatalf("Unable to compile %s: %s", *dicts, err)
+		}
+		if err := fd.Close(); err != nil {
+			log.Fatalf("Unable to write %s: %s", compiledFile, err)
+		}
+		log.Printf("Compiled %s", compiledFile)
+		return
+	}
 
 	if *personalDict != "" {
 		raw, err := ioutil.ReadFile(*personalDict)
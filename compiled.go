@@ -0,0 +1,340 @@
+package gospell
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+)
+
+// Compiled dictionary file layout
+//
+//	magic, version (uint32 little endian), then the sections as
+//	uvarint counts and lengths: the DictConfig as JSON, the word forms
+//	added to the store, see encodeWordForm, as the personal words, the
+//	stem index and the stem entries.  The index holds the offset of
+//	each entry (uint32 little endian), sorted by key, an entry is the
+//	lower case key and its homonyms with their flags.  The file ends
+//	with the CRC-32 (IEEE, little endian) of everything before it
+const (
+	compiledMagic   = "GOSPELLC"
+	compiledVersion = 4
+)
+
+// ErrCompiledFormat is returned for files that are not compiled
+// dictionaries or were written by another version
+var ErrCompiledFormat = errors.New("not a compiled dictionary")
+
+// compiledWriter writes the sections of a compiled dictionary
+type compiledWriter struct {
+	buf bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (w *compiledWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.tmp[:], v)
+	w.buf.Write(w.tmp[:n])
+}
+
+func (w *compiledWriter) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *compiledWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *compiledWriter) flags(flags []rune) {
+	w.uvarint(uint64(len(flags)))
+	for _, f := range flags {
+		w.uvarint(uint64(f))
+	}
+}
+
+// compiledReader reads the sections of a compiled dictionary, the
+// first error is kept in err
+type compiledReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *compiledReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var v uint64
+	for shift := uint(0); r.pos < len(r.data) && shift < 64; shift += 7 {
+		b := r.data[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+	r.err = ErrCompiledFormat
+	return 0
+}
+
+// count reads a count of items at least size bytes long each
+func (r *compiledReader) count(size int) int {
+	n := r.uvarint()
+	if n > uint64((len(r.data)-r.pos)/size) {
+		r.err = ErrCompiledFormat
+		return 0
+	}
+	return int(n)
+}
+
+// bytes slices the bytes read from data
+func (r *compiledReader) bytes() []byte {
+	n := r.count(1)
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *compiledReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *compiledReader) readFlags() []rune {
+	n := r.count(1)
+	if n == 0 {
+		return nil
+	}
+	flags := make([]rune, n)
+	for i := range flags {
+		flags[i] = rune(r.uvarint())
+	}
+	return flags
+}
+
+// homonyms reads the stems of a stem entry
+func (r *compiledReader) homonyms() []stem {
+	homonyms := make([]stem, r.count(2))
+	for i := range homonyms {
+		homonyms[i] = stem{word: r.string(), flags: r.readFlags()}
+	}
+	if r.err != nil {
+		return nil
+	}
+	return homonyms
+}
+
+// mappedStems is the stemTable of a compiled dictionary, the stems
+// are decoded from the file at each lookup
+type mappedStems struct {
+	index []byte // offsets of the entries in data, sorted by key
+	data  []byte
+	unmap func() error
+}
+
+// newMappedStems checks that the offsets of index are in data
+func newMappedStems(index, data []byte) (*mappedStems, error) {
+	if len(index)%4 != 0 {
+		return nil, ErrCompiledFormat
+	}
+	last := uint32(0)
+	for i := 0; i < len(index); i += 4 {
+		off := binary.LittleEndian.Uint32(index[i:])
+		if off < last || uint64(off) >= uint64(len(data)) {
+			return nil, ErrCompiledFormat
+		}
+		last = off
+	}
+	return &mappedStems{index: index, data: data}, nil
+}
+
+// entry returns the key of the entry i and a reader at its homonyms
+func (m *mappedStems) entry(i int) ([]byte, compiledReader) {
+	r := compiledReader{data: m.data, pos: int(binary.LittleEndian.Uint32(m.index[4*i:]))}
+	return r.bytes(), r
+}
+
+func (m *mappedStems) homonyms(key string) []stem {
+	n := len(m.index) / 4
+	i := sort.Search(n, func(i int) bool {
+		k, _ := m.entry(i)
+		return string(k) >= key
+	})
+	if i == n {
+		return nil
+	}
+	k, r := m.entry(i)
+	if string(k) != key {
+		return nil
+	}
+	return r.homonyms()
+}
+
+func (m *mappedStems) each(fn func(key string, homonyms []stem) bool) {
+	for i := 0; i < len(m.index)/4; i++ {
+		k, r := m.entry(i)
+		if !fn(string(k), r.homonyms()) {
+			return
+		}
+	}
+}
+
+// close unmaps the file of the stems, which are then empty
+func (m *mappedStems) close() error {
+	if m.unmap == nil {
+		return nil
+	}
+	unmap := m.unmap
+	m.unmap, m.index, m.data = nil, nil, nil
+	return unmap()
+}
+
+// Compile writes the dictionary and the personal words in the
+// compiled format read by NewGoSpellCompiled
+//
+//	Only dictionaries held in memory can be compiled.  The stems are
+//	written sorted with an index to be looked up in the mapped file,
+//	only the affix rules and the personal words are decoded when a
+//	compiled dictionary is loaded
+func (s *GoSpell) Compile(out io.Writer) error {
+	ms, ok := s.Store.(*memoryStore)
+	if !ok {
+		return errors.New("Only in-memory dictionaries can be compiled")
+	}
+	cfg, err := json.Marshal(s.Config)
+	if err != nil {
+		return err
+	}
+
+	w := compiledWriter{}
+	w.buf.WriteString(compiledMagic)
+	binary.Write(&w.buf, binary.LittleEndian, uint32(compiledVersion))
+	w.bytes(cfg)
+
+	keys := make([]string, 0, len(ms.forms))
+	for key := range ms.forms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		w.string(key)
+		w.uvarint(uint64(len(ms.forms[key])))
+		for _, wf := range ms.forms[key] {
+			w.string(encodeWordForm(wf))
+		}
+	}
+
+	stems := make(map[string][]stem)
+	keys = keys[:0]
+	ms.stems.each(func(key string, homonyms []stem) bool {
+		stems[key] = homonyms
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	entries := compiledWriter{}
+	index := make([]byte, 4*len(keys))
+	for i, key := range keys {
+		if uint64(entries.buf.Len()) > math.MaxUint32 {
+			return errors.New("Too many stems to compile")
+		}
+		binary.LittleEndian.PutUint32(index[4*i:], uint32(entries.buf.Len()))
+		entries.string(key)
+		entries.uvarint(uint64(len(stems[key])))
+		for _, st := range stems[key] {
+			entries.string(st.word)
+			entries.flags(st.flags)
+		}
+	}
+	w.bytes(index)
+	w.bytes(entries.buf.Bytes())
+
+	binary.Write(&w.buf, binary.LittleEndian, crc32.ChecksumIEEE(w.buf.Bytes()))
+	_, err = w.buf.WriteTo(out)
+	return err
+}
+
+// NewGoSpellCompiledReader creates GoSpell from a dictionary written
+// by Compile, the dictionary is read in memory
+func NewGoSpellCompiledReader(in io.Reader) (*GoSpell, error) {
+	raw, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	return newGoSpellCompiled(raw, nil)
+}
+
+// newGoSpellCompiled decodes the header, the affix rules and the
+// personal words of a compiled dictionary, the stems stay in raw
+func newGoSpellCompiled(raw []byte, unmap func() error) (*GoSpell, error) {
+	head := len(compiledMagic) + 4
+	if len(raw) < head+4 || string(raw[:len(compiledMagic)]) != compiledMagic {
+		return nil, ErrCompiledFormat
+	}
+	if v := binary.LittleEndian.Uint32(raw[len(compiledMagic):]); v != compiledVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrCompiledFormat, v, compiledVersion)
+	}
+	body := raw[:len(raw)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(raw[len(body):]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCompiledFormat)
+	}
+
+	r := compiledReader{data: body, pos: head}
+	var affix *DictConfig
+	if err := json.Unmarshal(r.bytes(), &affix); r.err != nil || err != nil || affix == nil {
+		return nil, fmt.Errorf("Unable to read Dict from compiled dictionary: %v", err)
+	}
+	if err := affix.compileConditions(); err != nil {
+		return nil, err
+	}
+
+	ms := newMemoryStore(affix, nil)
+	n := r.count(2)
+	for i := 0; i < n && r.err == nil; i++ {
+		key := r.string()
+		forms := make([]WordForm, r.count(1))
+		for j := range forms {
+			forms[j] = decodeWordForm(key, r.string())
+		}
+		ms.add(forms)
+	}
+
+	index, data := r.bytes(), r.bytes()
+	if r.err == nil && r.pos != len(r.data) {
+		r.err = ErrCompiledFormat
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	stems, err := newMappedStems(index, data)
+	if err != nil {
+		return nil, err
+	}
+	stems.unmap = unmap
+	ms.stems = stems
+	return NewGoSpellStore(affix, ms), nil
+}
+
+// NewGoSpellCompiled memory-maps a dictionary written by Compile, its
+// stems are looked up in the file.  It must be closed with Close
+func NewGoSpellCompiled(file string) (*GoSpell, error) {
+	raw, unmap, err := mmapFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open compiled dictionary: %s", err)
+	}
+	gs, err := newGoSpellCompiled(raw, unmap)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	return gs, nil
+}
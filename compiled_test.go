@@ -0,0 +1,64 @@
+package gospell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompiled(t *testing.T) {
+	gs, err := NewGoSpell("./sample/en_GB.aff", "./sample/en_GB.dic")
+	if err != nil {
+		t.Fatalf("Unable to load en_GB: %s", err)
+	}
+	gs.AddWordList(strings.NewReader("gospell\n*colour\n"))
+
+	buf := bytes.Buffer{}
+	if err := gs.Compile(&buf); err != nil {
+		t.Fatalf("Unable to compile: %s", err)
+	}
+	raw := buf.Bytes()
+	cgs, err := NewGoSpellCompiledReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unable to load compiled dictionary: %s", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "en_GB.gsc")
+	if err := os.WriteFile(file, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mgs, err := NewGoSpellCompiled(file)
+	if err != nil {
+		t.Fatalf("Unable to map compiled dictionary: %s", err)
+	}
+	defer mgs.Close()
+
+	words := []string{"colour", "Colours", "abandonware", "walked", "unlocked",
+		"121st", "121th", "e-mail-based", "gospell", "Gospell", "walkz", "London", "london",
+		"a", "zyzzyva", "zzz"}
+	for _, word := range words {
+		want := gs.Spell(word)
+		if got := cgs.Spell(word); want != got {
+			t.Errorf("%q want %v got %v", word, want, got)
+		}
+		if got := mgs.Spell(word); want != got {
+			t.Errorf("%q mapped want %v got %v", word, want, got)
+		}
+	}
+	if want, got := fmt.Sprint(gs.Suggest("colur", 3)), fmt.Sprint(mgs.Suggest("colur", 3)); want != got {
+		t.Errorf("Suggestions want %s got %s", want, got)
+	}
+
+	// any change is caught by the checksum
+	raw[len(raw)/2] ^= 1
+	if _, err := NewGoSpellCompiledReader(bytes.NewReader(raw)); !errors.Is(err, ErrCompiledFormat) {
+		t.Errorf("Corrupted dictionary loaded: %v", err)
+	}
+	if _, err := NewGoSpellCompiledReader(strings.NewReader("SET UTF-8\n")); !errors.Is(err, ErrCompiledFormat) {
+		t.Errorf("Affix file loaded as compiled dictionary: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package gospell
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompoundPattern is a CHECKCOMPOUNDPATTERN boundary forbidden
+// inside compound words
+//
+//	"CHECKCOMPOUNDPATTERN o/X b z" forbids a word ending with "o"
+//	and flagged X followed by a word starting with "b", but accepts
+//	the simplified boundary "z" instead, e.g. "foo" + "bar" is "fozar"
+type CompoundPattern struct {
+	End         string `json:"end,omitempty"` // end of the previous word
+	EndFlag     rune   `json:"end_flag,omitempty"`
+	Begin       string `json:"begin,omitempty"` // beginning of the next word
+	BeginFlag   rune   `json:"begin_flag,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// parseCompoundPattern parses the fields of a CHECKCOMPOUNDPATTERN
+// stanza: "endchars[/flag] beginchars[/flag] [replacement]"
+func (a DictConfig) parseCompoundPattern(fields []string) (CompoundPattern, error) {
+	var p CompoundPattern
+	if len(fields) != 2 && len(fields) != 3 {
+		return p, fmt.Errorf("CHECKCOMPOUNDPATTERN stanza had %d fields, expected 3 or 4", len(fields)+1)
+	}
+	var err error
+	if p.End, p.EndFlag, err = a.patternField(fields[0]); err != nil {
+		return p, err
+	}
+	if p.Begin, p.BeginFlag, err = a.patternField(fields[1]); err != nil {
+		return p, err
+	}
+	if len(fields) == 3 {
+		p.Replacement = fields[2]
+	}
+	return p, nil
+}
+
+// patternField splits "chars/flag", "0" is no characters
+func (a DictConfig) patternField(field string) (string, rune, error) {
+	text, flag := field, rune(0)
+	if idx := strings.IndexByte(field, '/'); idx != -1 {
+		var err error
+		flag, err = a.parseFlag("CHECKCOMPOUNDPATTERN", field[idx+1:])
+		if err != nil {
+			return "", 0, err
+		}
+		text = field[:idx]
+	}
+	if text == "0" {
+		text = ""
+	}
+	return text, flag, nil
+}
+
+// forbids returns true if the boundary between prev and next
+// matches the pattern
+func (p CompoundPattern) forbids(prev, next Form) bool {
+	return strings.HasSuffix(prev.Word, p.End) && strings.HasPrefix(next.Word, p.Begin) &&
+		(p.EndFlag == 0 || hasFlag(prev.Flags, p.EndFlag)) &&
+		(p.BeginFlag == 0 || hasFlag(next.Flags, p.BeginFlag))
+}
+
+// positions of a word inside a compound
+const (
+	compoundBegin = iota
+	compoundMiddle
+	compoundEnd
+)
+
+// isCompoundPart returns true if the flags allow a word form in
+// compounds made by COMPOUNDFLAG or COMPOUNDBEGIN/MIDDLE/END
+func (a DictConfig) isCompoundPart(flags []rune) bool {
+	return hasFlag(flags, a.CompoundFlag) || hasFlag(flags, a.CompoundBegin) ||
+		hasFlag(flags, a.CompoundMiddle) || hasFlag(flags, a.CompoundEnd)
+}
+
+// hasCompoundFlags returns true if the dictionary makes compounds
+// with COMPOUNDFLAG or COMPOUNDBEGIN/MIDDLE/END
+func (a DictConfig) hasCompoundFlags() bool {
+	return a.CompoundFlag != 0 || a.CompoundBegin != 0 || a.CompoundMiddle != 0 || a.CompoundEnd != 0
+}
+
+// compoundAllows returns true if the word form can be used at pos
+//
+//	As in Hunspell, prefixes are only allowed on the first word and
+//	suffixes only on the last one
+func (a DictConfig) compoundAllows(f Form, pos int) bool {
+	if hasFlag(f.Flags, a.CompoundFlag) {
+		switch pos {
+		case compoundBegin:
+			return !f.Suffixed
+		case compoundMiddle:
+			return !f.Prefixed && !f.Suffixed
+		default:
+			return !f.Prefixed
+		}
+	}
+	switch pos {
+	case compoundBegin:
+		return !f.Suffixed && hasFlag(f.Flags, a.CompoundBegin)
+	case compoundMiddle:
+		return !f.Prefixed && !f.Suffixed && hasFlag(f.Flags, a.CompoundMiddle)
+	default:
+		return !f.Prefixed && hasFlag(f.Flags, a.CompoundEnd)
+	}
+}
+
+// Decompose returns the dictionary word forms a compound word is made of,
+// or nil if the word is not a valid compound
+//
+//	Words are combined with COMPOUNDFLAG or COMPOUNDBEGIN, COMPOUNDMIDDLE
+//	and COMPOUNDEND, restricted by COMPOUNDMIN, COMPOUNDWORDMAX,
+//	FORCEUCASE and the CHECKCOMPOUND* stanzas, or by COMPOUNDRULE
+func (s *GoSpell) Decompose(word string) []string {
+	a := &s.Config
+	flags := a.hasCompoundFlags()
+	if !flags && len(s.rules) == 0 {
+		return nil
+	}
+	word = a.RemoveIgnored(word)
+	first, _ := utf8.DecodeRuneInString(word)
+	capitalized := unicode.IsUpper(first)
+
+	// as for single words, capitalized compounds may be written
+	// in lower case in the dictionary
+	variants := []string{word}
+	switch CaseStyle(word) {
+	case AllUpper:
+		lower := strings.ToLower(word)
+		variants = append(variants, lower, toTitle(lower))
+	case Title:
+		variants = append(variants, strings.ToLower(word))
+	}
+	for _, w := range variants {
+		if a.CheckCompoundRep && s.hasRepWord(w) {
+			continue
+		}
+		var parts []Form
+		if flags {
+			parts = s.decompose(w, nil, nil)
+		}
+		if parts == nil {
+			parts = s.matchRules(w)
+		}
+		if parts == nil {
+			continue
+		}
+		if hasFlag(parts[len(parts)-1].Flags, a.ForceUCaseFlag) && !capitalized {
+			continue
+		}
+		words := make([]string, len(parts))
+		for i, p := range parts {
+			words[i] = p.Word
+		}
+		return words
+	}
+	return nil
+}
+
+// decompose splits rest into compound parts following the ones in
+// found, via is the simplified CHECKCOMPOUNDPATTERN boundary between
+// the last part found and rest
+func (s *GoSpell) decompose(rest string, found []Form, via *CompoundPattern) []Form {
+	a := &s.Config
+	if a.CompoundWordMax > 0 && len(found) >= a.CompoundWordMax {
+		return nil
+	}
+	pos := compoundMiddle
+	if len(found) == 0 {
+		pos = compoundBegin
+	}
+	found = found[:len(found):len(found)]
+
+	// rest is the last word
+	if len(found) > 0 && utf8.RuneCountInString(rest) >= a.CompoundMin {
+		for _, f := range s.compoundForms(rest) {
+			if a.compoundAllows(f, compoundEnd) && s.compoundBoundary(found[len(found)-1], f, via) {
+				return append(found, f)
+			}
+		}
+	}
+
+	// rest starts with a word followed by at least another one
+	for i := range rest {
+		head := rest[:i]
+		if i == 0 || utf8.RuneCountInString(head) < a.CompoundMin {
+			continue
+		}
+		if utf8.RuneCountInString(rest[i:]) < a.CompoundMin {
+			break
+		}
+		for _, f := range s.compoundForms(head) {
+			if !a.compoundAllows(f, pos) || (len(found) > 0 && !s.compoundBoundary(found[len(found)-1], f, via)) {
+				continue
+			}
+			if parts := s.decompose(rest[i:], append(found, f), nil); parts != nil {
+				return parts
+			}
+		}
+	}
+
+	// simplified boundaries, the replacement stands for the end of
+	// a word and the beginning of the next one
+	for i := range a.CompoundPatterns {
+		p := &a.CompoundPatterns[i]
+		if p.Replacement == "" {
+			continue
+		}
+		for k := strings.Index(rest, p.Replacement); k > 0; {
+			head := rest[:k] + p.End
+			tail := p.Begin + rest[k+len(p.Replacement):]
+			for _, f := range s.compoundForms(head) {
+				if !a.compoundAllows(f, pos) || (p.EndFlag != 0 && !hasFlag(f.Flags, p.EndFlag)) {
+					continue
+				}
+				if len(found) > 0 && !s.compoundBoundary(found[len(found)-1], f, via) {
+					continue
+				}
+				if parts := s.decompose(tail, append(found, f), p); parts != nil {
+					return parts
+				}
+			}
+			next := strings.Index(rest[k+1:], p.Replacement)
+			if next == -1 {
+				break
+			}
+			k += next + 1
+		}
+	}
+	return nil
+}
+
+// compoundBoundary returns true if next can follow prev in a compound
+func (s *GoSpell) compoundBoundary(prev, next Form, via *CompoundPattern) bool {
+	a := &s.Config
+	if a.CheckCompoundDup && prev.Word == next.Word {
+		return false
+	}
+	if a.CheckCompoundCase {
+		last, _ := utf8.DecodeLastRuneInString(prev.Word)
+		first, _ := utf8.DecodeRuneInString(next.Word)
+		if unicode.IsUpper(last) || unicode.IsUpper(first) {
+			return false
+		}
+	}
+	for i := range a.CompoundPatterns {
+		p := &a.CompoundPatterns[i]
+		if p == via {
+			// the boundary was made by this pattern
+			if !strings.HasPrefix(next.Word, p.Begin) || (p.BeginFlag != 0 && !hasFlag(next.Flags, p.BeginFlag)) {
+				return false
+			}
+			continue
+		}
+		if p.forbids(prev, next) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasRepWord returns true if a REP replacement turns the word into
+// a dictionary word, the compound is then likely a misspelling
+func (s *GoSpell) hasRepWord(word string) bool {
+	for _, rep := range s.Config.Replacements {
+		if rep[0] == "" {
+			continue
+		}
+		for k := strings.Index(word, rep[0]); k != -1; {
+			candidate := word[:k] + rep[1] + word[k+len(rep[0]):]
+			if s.lookup(candidate) {
+				return true
+			}
+			next := strings.Index(word[k+1:], rep[0])
+			if next == -1 {
+				break
+			}
+			k += next + 1
+		}
+	}
+	return false
+}
+
+// lookup returns true if the word is a dictionary word on its own
+func (s *GoSpell) lookup(word string) bool {
+	return !s.IsForbidden(word) && s.inDictionary(word)
+}
+
+// compoundForms returns the word forms usable in compounds spelled
+// exactly as word
+func (s *GoSpell) compoundForms(word string) []Form {
+	var forms []Form
+	for _, wf := range s.lookupForms(word) {
+		if wf.Forbidden || wf.Flags == "" || wf.original() != word {
+			continue
+		}
+		forms = append(forms, Form{
+			Word:     word,
+			Flags:    parseFlagString(wf.Flags),
+			Prefixed: wf.Prefixed,
+			Suffixed: wf.Suffixed,
+		})
+	}
+	return forms
+}
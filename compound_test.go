@@ -0,0 +1,72 @@
+package gospell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestDecompose(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+COMPOUNDFLAG A
+COMPOUNDMIN 2
+ONLYINCOMPOUND O
+CHECKCOMPOUNDCASE
+CHECKCOMPOUNDPATTERN 1
+CHECKCOMPOUNDPATTERN o/X b/Y z
+
+SFX S Y 1
+SFX S 0 s .
+`
+
+	sampleDic := `6
+foo/AX
+bar/AY
+baz/A
+Bar/A
+ab/AO
+work/AS
+`
+	cases := []struct {
+		word string
+		want []string
+	}{
+		{"foo", nil},
+		{"bazwork", []string{"baz", "work"}},
+		{"bazworks", []string{"baz", "works"}},
+		{"workbaz", []string{"work", "baz"}},
+		{"worksbaz", nil},
+		{"abbaz", []string{"ab", "baz"}},
+		{"bazab", []string{"baz", "ab"}},
+		{"Bazab", []string{"baz", "ab"}},
+		{"BAZAB", []string{"baz", "ab"}},
+		{"foobar", nil},
+		{"fozar", []string{"foo", "bar"}},
+		{"foobaz", []string{"foo", "baz"}},
+		{"bazfoo", []string{"baz", "foo"}},
+		{"fooBar", nil},
+		{"Barfoo", []string{"Bar", "foo"}},
+	}
+
+	// both in memory and database word forms
+	for _, db := range []*gorm.DB{nil, createTable(t.TempDir()+"/compound.db", true, nil)} {
+		aff := strings.NewReader(sampleAff)
+		dic := strings.NewReader(sampleDic)
+		gs, err := NewGoSpellReader(aff, dic, db, "xx")
+		if err != nil {
+			t.Fatalf("Unable to create GoSpell: %s", err)
+		}
+		for pos, tt := range cases {
+			got := gs.Decompose(tt.word)
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("%d %q want %v got %v (db %v)", pos, tt.word, tt.want, got, db != nil)
+			}
+		}
+		if gs.Spell("ab") {
+			t.Errorf("ONLYINCOMPOUND word accepted alone (db %v)", db != nil)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package gospell
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf8"
+)
+
+// ruleElement is a flag of a COMPOUNDRULE with its quantifier
+type ruleElement struct {
+	flag     rune
+	optional bool // "?" or "*"
+	repeat   bool // "*"
+}
+
+// compoundRule is a COMPOUNDRULE compiled into a nondeterministic
+// automaton over the flags of the words of a compound
+//
+//	A state is the index of the next element to match, the set of
+//	current states fits in a bit mask
+type compoundRule struct {
+	elements []ruleElement
+}
+
+// maxRuleElements is the number of states a bit mask can hold,
+// including the final state
+const maxRuleElements = 63
+
+// newCompoundRule compiles a COMPOUNDRULE, e.g. "n*1t"
+func (a DictConfig) newCompoundRule(rule string) (*compoundRule, error) {
+	keys, err := a.CompoundRuleFlags(rule)
+	if err != nil {
+		return nil, err
+	}
+	cr := &compoundRule{}
+	for _, key := range keys {
+		switch key {
+		case -'?', -'*':
+			if len(cr.elements) == 0 {
+				return nil, fmt.Errorf("quantifier without flag")
+			}
+			last := &cr.elements[len(cr.elements)-1]
+			last.optional = true
+			last.repeat = key == -'*'
+		default:
+			cr.elements = append(cr.elements, ruleElement{flag: key})
+		}
+	}
+	if len(cr.elements) == 0 || len(cr.elements) > maxRuleElements {
+		return nil, fmt.Errorf("rule has %d flags, expected 1 to %d", len(cr.elements), maxRuleElements)
+	}
+	return cr, nil
+}
+
+// start returns the initial set of states
+func (cr *compoundRule) start() uint64 {
+	return cr.closure(1)
+}
+
+// closure adds the states reached by skipping optional elements
+func (cr *compoundRule) closure(states uint64) uint64 {
+	for i, e := range cr.elements {
+		if e.optional && states&(1<<uint(i)) != 0 {
+			states |= 1 << uint(i+1)
+		}
+	}
+	return states
+}
+
+// step returns the states after a word with the given flags
+func (cr *compoundRule) step(states uint64, flags []rune) uint64 {
+	var next uint64
+	for i, e := range cr.elements {
+		if states&(1<<uint(i)) == 0 || !hasFlag(flags, e.flag) {
+			continue
+		}
+		if e.repeat {
+			next |= 1 << uint(i)
+		} else {
+			next |= 1 << uint(i+1)
+		}
+	}
+	return cr.closure(next)
+}
+
+// final returns true if the rule is fully matched
+func (cr *compoundRule) final(states uint64) bool {
+	return states&(1<<uint(len(cr.elements))) != 0
+}
+
+// uses returns true if any of the flags is part of the rule
+func (cr *compoundRule) uses(flags []rune) bool {
+	for _, e := range cr.elements {
+		if hasFlag(flags, e.flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileCompoundRules builds the automata of COMPOUNDRULE
+func compileCompoundRules(affix *DictConfig) []*compoundRule {
+	rules := make([]*compoundRule, 0, len(affix.CompoundRule))
+	for _, compoundRule := range affix.CompoundRule {
+		cr, err := affix.newCompoundRule(compoundRule)
+		if err != nil {
+			log.Printf("COMPOUNDRULE FAIL= %q %s", compoundRule, err)
+			continue
+		}
+		rules = append(rules, cr)
+	}
+	return rules
+}
+
+// isRulePart returns true if a word form can be used by COMPOUNDRULE,
+// only stems without affixes are
+func (s *GoSpell) isRulePart(f Form) bool {
+	if f.Prefixed || f.Suffixed {
+		return false
+	}
+	for _, cr := range s.rules {
+		if cr.uses(f.Flags) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules splits the word into at least two dictionary words
+// whose flags match one of the COMPOUNDRULE automata
+func (s *GoSpell) matchRules(word string) []Form {
+	for _, cr := range s.rules {
+		if parts := s.matchRule(cr, word, cr.start(), nil); parts != nil {
+			return parts
+		}
+	}
+	return nil
+}
+
+// matchRule matches rest from the states reached after the parts
+// found
+func (s *GoSpell) matchRule(cr *compoundRule, rest string, states uint64, found []Form) []Form {
+	a := &s.Config
+	if rest == "" {
+		if len(found) > 1 && cr.final(states) {
+			return found
+		}
+		return nil
+	}
+	if a.CompoundWordMax > 0 && len(found) >= a.CompoundWordMax {
+		return nil
+	}
+	found = found[:len(found):len(found)]
+	for end := 1; end <= len(rest); end++ {
+		if end < len(rest) && !utf8.RuneStart(rest[end]) {
+			continue
+		}
+		if utf8.RuneCountInString(rest[:end]) < a.CompoundMin {
+			continue
+		}
+		for _, f := range s.compoundForms(rest[:end]) {
+			if f.Prefixed || f.Suffixed {
+				continue
+			}
+			next := cr.step(states, f.Flags)
+			if next == 0 {
+				continue
+			}
+			if parts := s.matchRule(cr, rest[end:], next, append(found, f)); parts != nil {
+				return parts
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,565 @@
+package gospell
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WordSet file layout
+//
+//	magic, version, meta length and meta bytes, root offset, nodes
+//	length and nodes, all numbers uint32 little endian.  A node is
+//	a final byte, the uvarint count of its edges, then the edges
+//	sorted by label as the UTF-8 label and the uint32 offset of the
+//	target node
+const (
+	wordSetMagic   = "GOSPELLD"
+	wordSetVersion = 1
+)
+
+// wordSetSep separates keys from their values
+const wordSetSep = 0
+
+// ErrWordSetFormat is returned for files that are not word sets or
+// were written by another version
+var ErrWordSetFormat = errors.New("not a word set")
+
+// WordSet is an immutable set of keys with values stored as a minimal
+// acyclic automaton (DAWG), which shares the common prefixes and
+// suffixes of the words.  It is queried in place, so a word set
+// opened by OpenWordSet is memory-mapped and shared by every
+// process using the file
+type WordSet struct {
+	meta  []byte
+	nodes []byte
+	root  uint32
+	unmap func() error
+}
+
+// node returns the final state and the edges of the node at off
+func (ws *WordSet) node(off uint32) (final bool, edges int, pos int) {
+	if uint64(off) >= uint64(len(ws.nodes)) {
+		return false, 0, 0
+	}
+	n, size := binary.Uvarint(ws.nodes[off+1:])
+	if size <= 0 {
+		return false, 0, 0
+	}
+	return ws.nodes[off] == 1, int(n), int(off) + 1 + size
+}
+
+// edge returns the edge at pos and the position of the next edge,
+// ok is false if the data is corrupted
+func (ws *WordSet) edge(pos int) (label rune, to uint32, next int, ok bool) {
+	if pos >= len(ws.nodes) {
+		return 0, 0, 0, false
+	}
+	label, size := utf8.DecodeRune(ws.nodes[pos:])
+	pos += size
+	if pos+4 > len(ws.nodes) {
+		return 0, 0, 0, false
+	}
+	return label, binary.LittleEndian.Uint32(ws.nodes[pos:]), pos + 4, true
+}
+
+// next follows the edge labelled r from the node at off
+func (ws *WordSet) next(off uint32, r rune) (uint32, bool) {
+	_, n, pos := ws.node(off)
+	for i := 0; i < n; i++ {
+		label, to, next, ok := ws.edge(pos)
+		if !ok || label > r {
+			return 0, false
+		}
+		if label == r {
+			return to, true
+		}
+		pos = next
+	}
+	return 0, false
+}
+
+// walk follows the runes of s from the root
+func (ws *WordSet) walk(s string) (uint32, bool) {
+	off := ws.root
+	for _, r := range s {
+		var ok bool
+		if off, ok = ws.next(off, r); !ok {
+			return 0, false
+		}
+	}
+	return off, true
+}
+
+// Meta returns the data stored with the word set
+func (ws *WordSet) Meta() []byte {
+	return ws.meta
+}
+
+// Contains returns true if key is in the set
+func (ws *WordSet) Contains(key string) bool {
+	off, ok := ws.walk(key)
+	if !ok {
+		return false
+	}
+	_, ok = ws.next(off, wordSetSep)
+	return ok
+}
+
+// Values calls fn with every value of key until fn returns false
+func (ws *WordSet) Values(key string, fn func(value string) bool) {
+	off, ok := ws.walk(key)
+	if !ok {
+		return
+	}
+	if off, ok = ws.next(off, wordSetSep); ok {
+		ws.enumerate(off, nil, false, fn)
+	}
+}
+
+// Prefix calls fn with every key starting with prefix, in order,
+// until fn returns false
+func (ws *WordSet) Prefix(prefix string, fn func(key string) bool) {
+	off, ok := ws.walk(prefix)
+	if !ok {
+		return
+	}
+	ws.enumerate(off, []byte(prefix), true, fn)
+}
+
+// enumerate calls fn with buf followed by every string accepted from
+// the node at off.  With keys, strings end at the separator instead
+func (ws *WordSet) enumerate(off uint32, buf []byte, keys bool, fn func(string) bool) bool {
+	final, n, pos := ws.node(off)
+	if final && !keys && !fn(string(buf)) {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		label, to, next, ok := ws.edge(pos)
+		if !ok {
+			return false
+		}
+		pos = next
+		if keys && label == wordSetSep {
+			if !fn(string(buf)) {
+				return false
+			}
+			continue
+		}
+		if !ws.enumerate(to, utf8.AppendRune(buf, label), keys, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fuzzy calls fn with every key within maxDist edits of word until
+// fn returns false.  An edit inserts, deletes or replaces a character
+// or swaps two adjacent ones (optimal string alignment distance)
+func (ws *WordSet) Fuzzy(word string, maxDist int, fn func(key string, dist int) bool) {
+	target := []rune(word)
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	ws.fuzzy(ws.root, target, maxDist, nil, nil, row, nil, fn)
+}
+
+// fuzzy extends the edit distance rows of key with the edges of the
+// node at off, skipping the subtrees that can not get within maxDist
+func (ws *WordSet) fuzzy(off uint32, target []rune, maxDist int, key []rune, prevRow, row []int, buf []byte, fn func(string, int) bool) bool {
+	_, n, pos := ws.node(off)
+	for i := 0; i < n; i++ {
+		label, to, next, ok := ws.edge(pos)
+		if !ok {
+			return false
+		}
+		pos = next
+		if label == wordSetSep {
+			if row[len(target)] <= maxDist && !fn(string(buf), row[len(target)]) {
+				return false
+			}
+			continue
+		}
+
+		rowMin := row[0]
+		for _, d := range row {
+			if d < rowMin {
+				rowMin = d
+			}
+		}
+		nextRow := make([]int, len(target)+1)
+		nextRow[0] = row[0] + 1
+		best := nextRow[0]
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if target[j-1] == label {
+				cost = 0
+			}
+			d := min3(row[j]+1, nextRow[j-1]+1, row[j-1]+cost)
+			if prevRow != nil && j > 1 && target[j-1] == key[len(key)-1] && target[j-2] == label && d > prevRow[j-2]+1 {
+				d = prevRow[j-2] + 1
+			}
+			nextRow[j] = d
+			if d < best {
+				best = d
+			}
+		}
+		// a swap after the next character still costs one more edit
+		// than the best distance so far
+		if best > maxDist && rowMin+1 > maxDist {
+			continue
+		}
+		if !ws.fuzzy(to, target, maxDist, append(key, label), row, nextRow, utf8.AppendRune(buf, label), fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Close releases the memory of a word set opened by OpenWordSet,
+// the word set can not be used afterwards
+func (ws *WordSet) Close() error {
+	if ws.unmap == nil {
+		return nil
+	}
+	unmap := ws.unmap
+	ws.unmap, ws.meta, ws.nodes = nil, nil, nil
+	return unmap()
+}
+
+// WriteTo writes the word set in the format read by OpenWordSet
+func (ws *WordSet) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString(wordSetMagic)
+	binary.Write(&buf, binary.LittleEndian, uint32(wordSetVersion))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(ws.meta)))
+	buf.Write(ws.meta)
+	binary.Write(&buf, binary.LittleEndian, ws.root)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(ws.nodes)))
+	buf.Write(ws.nodes)
+	return buf.WriteTo(w)
+}
+
+// NewWordSetBytes reads a word set written by WriteTo, data is used
+// in place and must not be modified
+func NewWordSetBytes(data []byte) (*WordSet, error) {
+	head := len(wordSetMagic) + 8
+	if len(data) < head || string(data[:len(wordSetMagic)]) != wordSetMagic {
+		return nil, ErrWordSetFormat
+	}
+	if v := binary.LittleEndian.Uint32(data[len(wordSetMagic):]); v != wordSetVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrWordSetFormat, v, wordSetVersion)
+	}
+	metaLen := uint64(binary.LittleEndian.Uint32(data[head-4:]))
+	if uint64(len(data)) < uint64(head)+metaLen+8 {
+		return nil, ErrWordSetFormat
+	}
+	ws := &WordSet{meta: data[head : head+int(metaLen)]}
+	data = data[head+int(metaLen):]
+	ws.root = binary.LittleEndian.Uint32(data)
+	nodesLen := uint64(binary.LittleEndian.Uint32(data[4:]))
+	if uint64(len(data)-8) != nodesLen || uint64(ws.root) >= nodesLen {
+		return nil, ErrWordSetFormat
+	}
+	ws.nodes = data[8:]
+	return ws, nil
+}
+
+// OpenWordSet memory-maps a word set written by WriteTo, it must be
+// closed with Close
+func OpenWordSet(file string) (*WordSet, error) {
+	data, unmap, err := mmapFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open word set: %s", err)
+	}
+	ws, err := NewWordSetBytes(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	ws.unmap = unmap
+	return ws, nil
+}
+
+// WordSetBuilder collects the keys and values of a WordSet
+type WordSetBuilder struct {
+	entries []string
+}
+
+// Add adds the value to key, keys can not contain the NUL character
+func (b *WordSetBuilder) Add(key, value string) {
+	b.entries = append(b.entries, key+string(rune(wordSetSep))+value)
+}
+
+// buildNode is a node of the automaton being built
+type buildNode struct {
+	final   bool
+	labels  []rune
+	targets []int
+}
+
+// buildBlock is the number of nodes allocated at once
+const buildBlock = 4096
+
+// buildNodes stores the nodes in blocks, which are never copied as
+// the automaton grows
+type buildNodes struct {
+	blocks [][]buildNode
+	n      int
+}
+
+// at returns the node i
+func (bn *buildNodes) at(i int) *buildNode {
+	return &bn.blocks[i/buildBlock][i%buildBlock]
+}
+
+// add returns a new empty node
+func (bn *buildNodes) add() int {
+	if bn.n%buildBlock == 0 {
+		bn.blocks = append(bn.blocks, make([]buildNode, buildBlock))
+	}
+	bn.n++
+	return bn.n - 1
+}
+
+// signature identifies the nodes accepting the same strings, once
+// their children are minimized
+func (n *buildNode) signature() string {
+	buf := make([]byte, 1, 1+len(n.labels)*8)
+	buf[0] = '0'
+	if n.final {
+		buf[0] = '1'
+	}
+	for i, label := range n.labels {
+		buf = utf8.AppendRune(buf, label)
+		buf = strconv.AppendInt(buf, int64(n.targets[i]), 10)
+		buf = append(buf, ',')
+	}
+	return string(buf)
+}
+
+// buildRegister holds the minimized nodes by signature.  Most nodes
+// have a single edge and are keyed by it, which hashes faster than
+// the signature strings
+type buildRegister struct {
+	single map[singleEdge]int
+	multi  map[string]int
+}
+
+// singleEdge is the key of the nodes of at most one edge
+type singleEdge struct {
+	final  bool
+	label  rune
+	target int
+}
+
+// equivalent returns the registered node equivalent to the node i,
+// or registers i
+func (r *buildRegister) equivalent(n *buildNode, i int) (int, bool) {
+	if len(n.labels) > 1 {
+		sig := n.signature()
+		if same, ok := r.multi[sig]; ok {
+			return same, true
+		}
+		r.multi[sig] = i
+		return i, false
+	}
+	key := singleEdge{final: n.final, target: -1}
+	if len(n.labels) == 1 {
+		key.label, key.target = n.labels[0], n.targets[0]
+	}
+	if same, ok := r.single[key]; ok {
+		return same, true
+	}
+	r.single[key] = i
+	return i, false
+}
+
+// Build returns the minimal automaton of the entries with meta as
+// its data, see Meta
+//
+//	This is the incremental construction of sorted words by Daciuk,
+//	Mihov, Watson and Watson (2000)
+func (b *WordSetBuilder) Build(meta []byte) *WordSet {
+	sort.Strings(b.entries)
+	nodes := buildNodes{}
+	nodes.add()
+	register := buildRegister{single: make(map[singleEdge]int), multi: make(map[string]int)}
+	type pending struct{ parent, child int }
+	var unchecked []pending
+	var free []int // replaced nodes, reused for the next words
+
+	// minimize replaces the unchecked nodes below depth with the
+	// registered equivalent ones
+	minimize := func(depth int) {
+		for i := len(unchecked) - 1; i >= depth; i-- {
+			u := unchecked[i]
+			child := nodes.at(u.child)
+			if same, ok := register.equivalent(child, u.child); ok {
+				parent := nodes.at(u.parent)
+				parent.targets[len(parent.targets)-1] = same
+				*child = buildNode{labels: child.labels[:0], targets: child.targets[:0]}
+				free = append(free, u.child)
+			}
+		}
+		unchecked = unchecked[:depth]
+	}
+
+	prev := ""
+	for i, entry := range b.entries {
+		if i > 0 && entry == prev {
+			continue
+		}
+		// the common prefix of whole runes, unchecked has a node by rune
+		common := 0
+		for common < len(entry) && common < len(prev) && entry[common] == prev[common] {
+			common++
+		}
+		for common > 0 && common < len(entry) && !utf8.RuneStart(entry[common]) {
+			common--
+		}
+		minimize(utf8.RuneCountInString(entry[:common]))
+		node := 0
+		if len(unchecked) > 0 {
+			node = unchecked[len(unchecked)-1].child
+		}
+		for _, r := range entry[common:] {
+			var child int
+			if len(free) > 0 {
+				child, free = free[len(free)-1], free[:len(free)-1]
+			} else {
+				child = nodes.add()
+			}
+			parent := nodes.at(node)
+			parent.labels = append(parent.labels, r)
+			parent.targets = append(parent.targets, child)
+			unchecked = append(unchecked, pending{node, child})
+			node = child
+		}
+		nodes.at(node).final = true
+		prev = entry
+	}
+	minimize(0)
+
+	// children are laid out before their parents
+	offsets := make([]int, nodes.n)
+	for i := range offsets {
+		offsets[i] = -1
+	}
+	var order []int
+	size := 0
+	var visit func(int)
+	visit = func(n int) {
+		offsets[n] = 0
+		node := nodes.at(n)
+		for _, t := range node.targets {
+			if offsets[t] == -1 {
+				visit(t)
+			}
+		}
+		offsets[n] = size
+		size += 1 + uvarintLen(uint64(len(node.labels)))
+		for _, label := range node.labels {
+			size += utf8.RuneLen(label) + 4
+		}
+		order = append(order, n)
+	}
+	visit(0)
+
+	out := make([]byte, 0, size)
+	var tmp [binary.MaxVarintLen64]byte
+	for _, n := range order {
+		node := nodes.at(n)
+		final := byte(0)
+		if node.final {
+			final = 1
+		}
+		out = append(out, final)
+		out = append(out, tmp[:binary.PutUvarint(tmp[:], uint64(len(node.labels)))]...)
+		for i, label := range node.labels {
+			out = utf8.AppendRune(out, label)
+			out = binary.LittleEndian.AppendUint32(out, uint32(offsets[node.targets[i]]))
+		}
+	}
+	return &WordSet{meta: meta, nodes: out, root: uint32(offsets[0])}
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// Word form bits stored in the word set values
+const (
+	wordSetKeepCase = 1 << (iota + 2) // the low bits are the WordCase
+	wordSetForbidden
+	wordSetPrefixed
+	wordSetSuffixed
+	wordSetNoSuggest
+)
+
+// encodeWordForm encodes the word form as a word set value: the
+// bits, the original spelling of Mixed words, NUL and the flags
+func encodeWordForm(wf WordForm) string {
+	bits := int(wf.Case)
+	if wf.KeepCase {
+		bits |= wordSetKeepCase
+	}
+	if wf.Forbidden {
+		bits |= wordSetForbidden
+	}
+	if wf.Prefixed {
+		bits |= wordSetPrefixed
+	}
+	if wf.Suffixed {
+		bits |= wordSetSuffixed
+	}
+	if wf.NoSuggest {
+		bits |= wordSetNoSuggest
+	}
+	// the bits are offset to keep them printable, the NoSuggest bit
+	// makes a two byte rune
+	return string(rune('0'+bits)) + wf.Original + string(rune(wordSetSep)) + wf.Flags
+}
+
+// decodeWordForm decodes a word set value of key
+func decodeWordForm(key, value string) WordForm {
+	bits := 0
+	if value != "" {
+		r, n := utf8.DecodeRuneInString(value)
+		bits = int(r) - '0'
+		value = value[n:]
+	}
+	original, flags, _ := strings.Cut(value, string(rune(wordSetSep)))
+	return WordForm{
+		Word:      key,
+		Original:  original,
+		Case:      WordCase(bits & 3),
+		KeepCase:  bits&wordSetKeepCase != 0,
+		Forbidden: bits&wordSetForbidden != 0,
+		Prefixed:  bits&wordSetPrefixed != 0,
+		Suffixed:  bits&wordSetSuffixed != 0,
+		NoSuggest: bits&wordSetNoSuggest != 0,
+		Flags:     flags,
+	}
+}
@@ -0,0 +1,121 @@
+package gospell
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWordSet(t *testing.T) {
+	keys := []string{"cat", "cats", "car", "cart", "dog", "dogs", "ёж", "ежи", "tac", "act"}
+	b := WordSetBuilder{}
+	for _, key := range keys {
+		b.Add(key, "v"+key)
+	}
+	b.Add("cat", "second")
+	b.Add("cat", "second")
+
+	buf := bytes.Buffer{}
+	if _, err := b.Build([]byte("meta")).WriteTo(&buf); err != nil {
+		t.Fatalf("Unable to write: %s", err)
+	}
+	ws, err := NewWordSetBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Unable to read: %s", err)
+	}
+	if string(ws.Meta()) != "meta" {
+		t.Errorf("Meta want %q got %q", "meta", ws.Meta())
+	}
+
+	for _, key := range keys {
+		if !ws.Contains(key) {
+			t.Errorf("%q not found", key)
+		}
+	}
+	for _, key := range []string{"", "ca", "catss", "do", "vcat", "ёжи"} {
+		if ws.Contains(key) {
+			t.Errorf("%q found", key)
+		}
+	}
+
+	var values []string
+	ws.Values("cat", func(v string) bool {
+		values = append(values, v)
+		return true
+	})
+	if want := []string{"second", "vcat"}; !reflect.DeepEqual(want, values) {
+		t.Errorf("Values want %v got %v", want, values)
+	}
+
+	var got []string
+	ws.Prefix("ca", func(key string) bool {
+		got = append(got, key)
+		return true
+	})
+	if want := []string{"car", "cart", "cat", "cats"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("Prefix want %v got %v", want, got)
+	}
+
+	for _, word := range []string{"cta", "dgo", "crat", "ежт", "x", "catsdogs"} {
+		for maxDist := 0; maxDist <= 2; maxDist++ {
+			var want, got []string
+			for _, key := range keys {
+				if editDistance(word, key) <= maxDist {
+					want = append(want, key)
+				}
+			}
+			ws.Fuzzy(word, maxDist, func(key string, dist int) bool {
+				if dist != editDistance(word, key) {
+					t.Errorf("Fuzzy %q %q distance %d", word, key, dist)
+				}
+				got = append(got, key)
+				return true
+			})
+			sort.Strings(want)
+			sort.Strings(got)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("Fuzzy %q %d want %v got %v", word, maxDist, want, got)
+			}
+		}
+	}
+
+	if _, err := NewWordSetBytes([]byte("GOSPELLC")); !errors.Is(err, ErrWordSetFormat) {
+		t.Errorf("Invalid word set loaded: %v", err)
+	}
+}
+
+func TestWordSetConformance(t *testing.T) {
+	affFiles, err := filepath.Glob("testdata/*.aff")
+	if err != nil || len(affFiles) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+	for _, affFile := range affFiles {
+		name := strings.TrimSuffix(affFile, ".aff")
+		gs, err := NewGoSpellWordSetForce(affFile, name+".dic", filepath.Join(t.TempDir(), "words.dawg"))
+		if err != nil {
+			t.Errorf("%s: unable to create GoSpell: %s", name, err)
+			continue
+		}
+		for _, want := range []bool{true, false} {
+			ext := ".good"
+			if !want {
+				ext = ".wrong"
+			}
+			raw, err := os.ReadFile(name + ext)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			for _, word := range strings.Fields(string(raw)) {
+				if gs.Spell(word) != want {
+					t.Errorf("%s: %q was not %v", name, word, want)
+				}
+			}
+		}
+		gs.Close()
+	}
+}
@@ -0,0 +1,84 @@
+package gospell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// utf8BOM is skipped at the start of AFF and DIC files
+const utf8BOM = "\uFEFF"
+
+// charsets maps the SET names used by Hunspell dictionaries
+// to their single byte encodings
+var charsets = map[string]*charmap.Charmap{
+	"ISO8859-1":        charmap.ISO8859_1,
+	"ISO8859-2":        charmap.ISO8859_2,
+	"ISO8859-3":        charmap.ISO8859_3,
+	"ISO8859-4":        charmap.ISO8859_4,
+	"ISO8859-5":        charmap.ISO8859_5,
+	"ISO8859-6":        charmap.ISO8859_6,
+	"ISO8859-7":        charmap.ISO8859_7,
+	"ISO8859-8":        charmap.ISO8859_8,
+	"ISO8859-9":        charmap.ISO8859_9,
+	"ISO8859-10":       charmap.ISO8859_10,
+	"ISO8859-13":       charmap.ISO8859_13,
+	"ISO8859-14":       charmap.ISO8859_14,
+	"ISO8859-15":       charmap.ISO8859_15,
+	"KOI8-R":           charmap.KOI8R,
+	"KOI8-U":           charmap.KOI8U,
+	"MICROSOFT-CP1251": charmap.Windows1251,
+	"CP1251":           charmap.Windows1251,
+	"WINDOWS-1251":     charmap.Windows1251,
+	"CP1252":           charmap.Windows1252,
+	"WINDOWS-1252":     charmap.Windows1252,
+	"TIS620-2533":      charmap.Windows874,
+}
+
+// charset returns the encoding for a SET name, nil means UTF-8
+//
+//	Names are case insensitive and "ISO-8859-1" is the same as "ISO8859-1"
+func charset(set string) (*charmap.Charmap, error) {
+	name := strings.ToUpper(set)
+	if name == "" || name == "UTF-8" || name == "UTF8" {
+		return nil, nil
+	}
+	name = strings.Replace(name, "ISO-8859", "ISO8859", 1)
+	cm, ok := charsets[name]
+	if !ok {
+		return nil, fmt.Errorf("SET encoding %q is not supported", set)
+	}
+	return cm, nil
+}
+
+// findSet returns the value of the SET stanza, which
+// may appear anywhere in an AFF file.  All supported
+// encodings are ASCII compatible so raw bytes are fine
+func findSet(raw []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 2 && parts[0] == "SET" {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// DecodeReader returns a reader converting r from the dictionary
+// encoding (the SET stanza) to UTF-8
+func (a DictConfig) DecodeReader(r io.Reader) (io.Reader, error) {
+	cm, err := charset(a.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if cm == nil {
+		return r, nil
+	}
+	return transform.NewReader(r, cm.NewDecoder()), nil
+}
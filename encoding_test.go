@@ -0,0 +1,100 @@
+package gospell
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestCharset(t *testing.T) {
+	cases := []struct {
+		set  string
+		want *charmap.Charmap
+	}{
+		{"UTF-8", nil},
+		{"", nil},
+		{"KOI8-R", charmap.KOI8R},
+		{"koi8-r", charmap.KOI8R},
+		{"ISO8859-1", charmap.ISO8859_1},
+		{"ISO-8859-15", charmap.ISO8859_15},
+		{"microsoft-cp1251", charmap.Windows1251},
+	}
+	for pos, tt := range cases {
+		got, err := charset(tt.set)
+		if err != nil {
+			t.Errorf("%d: %s", pos, err)
+		}
+		if got != tt.want {
+			t.Errorf("%d: %q want %v got %v", pos, tt.set, tt.want, got)
+		}
+	}
+	if _, err := charset("ISCII-DEVANAGARI"); err == nil {
+		t.Errorf("Expected error for unsupported encoding")
+	}
+}
+
+func TestSpellEncodings(t *testing.T) {
+	// SET is deliberately not the first stanza
+	sampleAff := `
+TRY оеаинтсрвлкмдпуяыьгзбчйхжшюцщэфёъ
+SET %s
+
+SFX A Y 2
+SFX A а ы а
+SFX A а у а
+`
+	sampleDic := `2
+ракета/A
+ёж
+`
+	cases := []struct {
+		word  string
+		spell bool
+	}{
+		{"ракета", true},
+		{"ракеты", true},
+		{"Ракету", true},
+		{"ёж", true},
+		{"рокета", false},
+	}
+
+	for _, set := range []string{"KOI8-R", "microsoft-cp1251", "UTF-8"} {
+		cm, err := charset(set)
+		if err != nil {
+			t.Fatalf("%s: %s", set, err)
+		}
+		aff := strings.Replace(sampleAff, "%s", set, 1)
+		dic := sampleDic
+		if cm != nil {
+			if aff, err = cm.NewEncoder().String(aff); err != nil {
+				t.Fatalf("%s: unable to encode aff: %s", set, err)
+			}
+			if dic, err = cm.NewEncoder().String(dic); err != nil {
+				t.Fatalf("%s: unable to encode dic: %s", set, err)
+			}
+		}
+
+		gs, err := NewGoSpellReader(strings.NewReader(aff), strings.NewReader(dic), nil, "")
+		if err != nil {
+			t.Fatalf("%s: unable to create GoSpell: %s", set, err)
+		}
+		if gs.Config.TryChars != "оеаинтсрвлкмдпуяыьгзбчйхжшюцщэфёъ" {
+			t.Errorf("%s: TRY stanza is %q", set, gs.Config.TryChars)
+		}
+		for pos, tt := range cases {
+			if gs.Spell(tt.word) != tt.spell {
+				t.Errorf("%s: %d %q was not %v", set, pos, tt.word, tt.spell)
+			}
+		}
+	}
+
+	// byte order mark before the word count
+	gs, err := NewGoSpellReader(strings.NewReader(utf8BOM+"SET UTF-8\n"), strings.NewReader(utf8BOM+"1\nёж\n"), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to read files with BOM: %s", err)
+	}
+	if !gs.Spell("ёж") {
+		t.Errorf("Word after BOM not found")
+	}
+}
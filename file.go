@@ -13,6 +13,7 @@ type Diff struct {
 	Original string
 	Line     string
 	LineNum  int
+	Join     string // correct word of Original and the next unknown word, see SuggestJoin
 }
 
 // SpellFile is attempts to spell-check a file.  This interface is not
@@ -38,16 +39,28 @@ func SpellFile(gs *GoSpell, ext plaintext.Extractor, raw []byte) []Diff {
 	for linenum, line := range strings.Split(s, "\n") {
 		// now get words
 		words := gs.Split(line)
-		for _, word := range words {
+		known := make([]bool, len(words))
+		for i, word := range words {
 			// HACK
-			word = strings.Trim(word, "'")
-			if known := gs.Spell(word); !known {
-				out = append(out, Diff{
-					Line:     line,
-					LineNum:  linenum + 1,
-					Original: word,
-				})
+			words[i] = strings.Trim(word, "'")
+			known[i] = gs.Spell(words[i])
+		}
+		for i, word := range words {
+			if known[i] {
+				continue
+			}
+			diff := Diff{
+				Line:     line,
+				LineNum:  linenum + 1,
+				Original: gs.OutputConversion(word),
+			}
+			// two unknown fragments of a word split by mistake
+			if i+1 < len(words) && !known[i+1] {
+				if joined, ok := gs.SuggestJoin(word, words[i+1]); ok {
+					diff.Join = gs.OutputConversion(joined)
+				}
 			}
+			out = append(out, diff)
 		}
 	}
 	return out
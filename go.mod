@@ -7,6 +7,7 @@ require (
 	github.com/naoina/toml v0.1.1
 	github.com/ryanuber/go-glob v1.0.0
 	golang.org/x/net v0.0.0-20221014081412-f15817d10f9b
+	golang.org/x/text v0.3.8
 	gorm.io/driver/sqlite v1.4.2
 	gorm.io/gorm v1.24.0
 	gorm.io/plugin/dbresolver v1.3.0
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -22,19 +21,42 @@ import (
 // GoSpell is main struct
 type GoSpell struct {
 	Config    DictConfig
-	Dict      map[string]struct{} // likely will contain some value later
-	DB        *gorm.DB
-	ireplacer *strings.Replacer // input conversion
-	compounds []*regexp.Regexp
+	Frequency map[string]int      // word counts ranking the suggestions, see AddFrequencyList
+	Store     WordStore           // dictionary word forms
+	DB        *gorm.DB            // database of Store, if any
+	ireplacer *strings.Replacer   // input conversion
+	oreplacer *strings.Replacer   // output conversion
+	rules     []*compoundRule     // COMPOUNDRULE automata
+	phonetic  func(string) string // phonetic code, nil without PHONE or metaphone
 	splitter  *Splitter
+
+	// Deprecated: Dict is not read, the personal words of AddWordRaw
+	// and AddWordList are added to Store
+	Dict map[string]struct{}
 }
 
 // WordForm — структура для базы данных
 type WordForm struct {
-	ID   uint   `gorm:"primaryKey"`
-	Word string `gorm:"index"`
-	Lang string
-	Case WordCase
+	ID        uint   `gorm:"primaryKey"`
+	Word      string `gorm:"index"`
+	Original  string // написание слова в словаре, только для Mixed
+	Lang      string
+	Case      WordCase
+	KeepCase  bool
+	Forbidden bool
+	Flags     string // флаги словоформ для сложных слов, см. flagString
+	Prefixed  bool
+	Suffixed  bool
+	Phonetic  string `gorm:"index"` // фонетический код слова, см. PhoneticStore
+	NoSuggest bool   // слово верно, но не предлагается в подсказках
+}
+
+// original возвращает написание словоформы в словаре
+func (wf WordForm) original() string {
+	if wf.Original != "" {
+		return wf.Original
+	}
+	return applyCase(wf.Word, wf.Case)
 }
 
 // Preferences - настройки, хранящиеся в базе данных
@@ -60,6 +82,17 @@ func (s *GoSpell) InputConversion(raw []byte) string {
 	return s.ireplacer.Replace(sraw)
 }
 
+// OutputConversion does any character substitution on words
+// returned to the user, e.g. suggestions
+//
+//	This is based on the OCONV stanza
+func (s *GoSpell) OutputConversion(word string) string {
+	if s.oreplacer == nil {
+		return word
+	}
+	return s.oreplacer.Replace(word)
+}
+
 // Split a text into Words
 func (s *GoSpell) Split(text string) []string {
 	return s.splitter.Split(text)
@@ -68,14 +101,66 @@ func (s *GoSpell) Split(text string) []string {
 // AddWordRaw adds a single word to the internal dictionary without modifications
 // returns true if added
 // return false is already exists
+//
+//	The word is added to Store as a word form valid as written only,
+//	a database store keeps it
 func (s *GoSpell) AddWordRaw(word string) bool {
-	_, ok := s.Dict[word]
-	if ok {
-		// already exists
+	if s.Store == nil {
+		return false
+	}
+	wf := s.personalForm(word)
+	wf.KeepCase = true
+	wfs, err := s.Store.Lookup(wf.Word)
+	if err != nil {
 		return false
 	}
-	s.Dict[word] = struct{}{}
-	return true
+	for _, other := range wfs {
+		if other.KeepCase && !other.Forbidden && other.original() == word {
+			// already exists
+			return false
+		}
+	}
+	return s.Store.Add(wf) == nil
+}
+
+// AddWordForbidden marks a word and its case variations as forbidden,
+// Spell will reject them even if they are in the dictionary
+//
+//	The word is added to Store as a forbidden word form
+func (s *GoSpell) AddWordForbidden(word string) {
+	if s.Store == nil {
+		return
+	}
+	wf := s.personalForm(word)
+	wf.Forbidden = true
+	s.Store.Add(wf)
+}
+
+// personalForm returns the word form of a personal word
+func (s *GoSpell) personalForm(word string) WordForm {
+	wf, _ := s.newWordForm(Form{Word: word}, s.Config.Lang)
+	return wf
+}
+
+// IsForbidden returns true if the word is forbidden by the
+// dictionary or a personal word list
+func (s *GoSpell) IsForbidden(word string) bool {
+	return s.isForbidden(word, s.lookupForms(word))
+}
+
+// isForbidden is IsForbidden with the stored word forms of the word
+func (s *GoSpell) isForbidden(word string, wfs []WordForm) bool {
+	for _, wf := range wfs {
+		if !wf.Forbidden {
+			continue
+		}
+		for _, variant := range CaseVariations(wf.original(), wf.Case) {
+			if variant == word {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // AddWordListFile reads in a word list file
@@ -92,8 +177,8 @@ func (s *GoSpell) AddWordListFile(name string) ([]string, error) {
 //
 //	Assumed to be in UTF-8
 //
-// TODO: hunspell compatible with "*" prefix for forbidden words
-// and affix support
+// As in hunspell, words with a "*" prefix are forbidden
+// TODO: affix support
 // returns list of duplicated words and/or error
 func (s *GoSpell) AddWordList(r io.Reader) ([]string, error) {
 	var duplicates []string
@@ -103,6 +188,10 @@ func (s *GoSpell) AddWordList(r io.Reader) ([]string, error) {
 		if len(line) == 0 || line == "#" {
 			continue
 		}
+		if strings.HasPrefix(line, "*") && len(line) > 1 {
+			s.AddWordForbidden(line[1:])
+			continue
+		}
 		for _, word := range CaseVariations(line, CaseStyle(line)) {
 			if !s.AddWordRaw(word) {
 				duplicates = append(duplicates, word)
@@ -115,27 +204,102 @@ func (s *GoSpell) AddWordList(r io.Reader) ([]string, error) {
 	return duplicates, nil
 }
 
+// maxBreaks is the number of BREAK points from which words are not
+// broken, as in Hunspell
+const maxBreaks = 10
+
 // Spell checks to see if a given word is in the internal dictionaries
 // TODO: add multiple dictionaries
 func (s *GoSpell) Spell(word string) bool {
-	if s.DB == nil {
-		_, ok := s.Dict[word]
-		if ok {
+	return s.spellBreak(s.Config.RemoveIgnored(word))
+}
+
+// spellBreak checks the word, then its parts split at the BREAK
+// patterns, "^-" only breaks at the start and "-$" at the end
+//
+//	Words of maxBreaks or more break points are not broken, and
+//	each part is checked once
+func (s *GoSpell) spellBreak(word string) bool {
+	wfs := s.lookupForms(word)
+	if s.spellWord(word, wfs) {
+		return true
+	}
+	if len(s.Config.Break) == 0 || s.isForbidden(word, wfs) || s.breakPoints(word) >= maxBreaks {
+		return false
+	}
+	return s.spellParts(word, 0, len(word), map[[2]int]bool{})
+}
+
+// breakPoints counts the occurrences of the BREAK patterns in the word
+func (s *GoSpell) breakPoints(word string) int {
+	n := 0
+	for _, brk := range s.Config.Break {
+		n += strings.Count(word, brk)
+	}
+	return n
+}
+
+// spellParts checks word[i:j] broken at the BREAK patterns, the
+// results of the parts are kept in checked
+func (s *GoSpell) spellParts(word string, i, j int, checked map[[2]int]bool) bool {
+	key := [2]int{i, j}
+	if ok, done := checked[key]; done {
+		return ok
+	}
+	checked[key] = false
+	part := word[i:j]
+	if i > 0 || j < len(word) {
+		wfs := s.lookupForms(part)
+		if s.spellWord(part, wfs) {
+			checked[key] = true
 			return true
 		}
-	} else {
-		var wf WordForm
-		s.DB.Where("word = ?", strings.ToLower(word)).First(&wf)
-		if wf.Case == Mixed {
-			return true
+		if s.isForbidden(part, wfs) {
+			return false
 		}
-		if wf.Case == AllUpper && word == strings.ToUpper(word) {
-			return true
+	}
+	ok := false
+	for _, brk := range s.Config.Break {
+		switch {
+		case len(brk) > 1 && strings.HasPrefix(brk, "^"):
+			brk = brk[1:]
+			ok = len(part) > len(brk) && strings.HasPrefix(part, brk) &&
+				s.spellParts(word, i+len(brk), j, checked)
+		case len(brk) > 1 && strings.HasSuffix(brk, "$"):
+			brk = brk[:len(brk)-1]
+			ok = len(part) > len(brk) && strings.HasSuffix(part, brk) &&
+				s.spellParts(word, i, j-len(brk), checked)
+		default:
+			for k := strings.Index(part, brk); k != -1 && !ok; {
+				if k > 0 && k+len(brk) < len(part) {
+					ok = s.spellParts(word, i, i+k, checked) && s.spellParts(word, i+k+len(brk), j, checked)
+				}
+				next := strings.Index(part[k+1:], brk)
+				if next == -1 {
+					break
+				}
+				k += next + 1
+			}
 		}
-		if wf.Case == Title && (word == strings.ToUpper(word) || word == strings.ToTitle(word)) {
-			return true
+		if ok {
+			break
 		}
 	}
+	checked[key] = ok
+	return ok
+}
+
+// spellWord checks a word without breaking it, wfs are its stored
+// word forms
+func (s *GoSpell) spellWord(word string, wfs []WordForm) bool {
+	// forbidden words win over every other check
+	if s.isForbidden(word, wfs) {
+		return false
+	}
+
+	if s.spellWordForms(word, wfs) {
+		return true
+	}
 	if isNumber(word) {
 		return true
 	}
@@ -156,46 +320,27 @@ func (s *GoSpell) Spell(word string) bool {
 	}
 
 	// check compounds
-	for _, pat := range s.compounds {
-		if pat.MatchString(word) {
-			return true
-		}
+	if s.Decompose(word) != nil {
+		return true
 	}
 
-	// Maybe a word with units? e.g. 100GB
-	units := isNumberUnits(word)
-	if units != "" {
-		if s.DB == nil {
-			// dictionary appears to have list of units
-			if _, ok := s.Dict[units]; ok {
-				return true
-			}
-		} else {
-			r := s.DB.First(&WordForm{
-				Word: units,
-			})
-			if !errors.Is(r.Error, gorm.ErrRecordNotFound) {
-				return true
-			}
-		}
+	// Maybe a word with units? e.g. 100GB, if the dictionary has units
+	if units := isNumberUnits(word); units != "" && s.lookup(units) {
+		return true
 	}
 
 	// if camelCase and each word e.g. "camel" "Case" is know
 	// then the word is considered known
 	if chunks := splitCamelCase(word); len(chunks) > 0 {
+		for _, chunk := range chunks {
+			if s.IsForbidden(chunk) {
+				return false
+			}
+		}
 		if false {
 			for _, chunk := range chunks {
-				if s.DB == nil {
-					if _, ok := s.Dict[chunk]; !ok {
-						return false
-					}
-				} else {
-					r := s.DB.First(&WordForm{
-						Word: chunk,
-					})
-					if errors.Is(r.Error, gorm.ErrRecordNotFound) {
-						return false
-					}
+				if !s.inDictionary(chunk) {
+					return false
 				}
 			}
 		}
@@ -205,64 +350,158 @@ func (s *GoSpell) Spell(word string) bool {
 	return false
 }
 
+// inDictionary returns true if the word is in the dictionary or
+// the personal words
+func (s *GoSpell) inDictionary(word string) bool {
+	return s.spellWordForms(word, s.lookupForms(word))
+}
+
+// lookupForms is findForms taking the errors of the store as not found
+func (s *GoSpell) lookupForms(word string) []WordForm {
+	wfs, _ := s.findForms(word)
+	return wfs
+}
+
+// findForms returns the stored word forms that may be spelled as word
+func (s *GoSpell) findForms(word string) ([]WordForm, error) {
+	if s.Store == nil {
+		return nil, nil
+	}
+	var wfs []WordForm
+	for _, key := range s.lookupKeys(word) {
+		found, err := s.Store.Lookup(key)
+		if err != nil {
+			return wfs, err
+		}
+		wfs = append(wfs, found...)
+	}
+	return wfs, nil
+}
+
+// lookupKeys returns the lower case words findForms looks up: with
+// CHECKSHARPS, "SS" may be a sharp s as well
+func (s *GoSpell) lookupKeys(word string) []string {
+	lower := strings.ToLower(word)
+	if s.Config.CheckSharps && strings.Contains(word, "SS") {
+		return []string{lower, strings.ReplaceAll(lower, "ss", "ß")}
+	}
+	return []string{lower}
+}
+
+// spellWordForms returns true if one of the stored word forms
+// wfs is spelled as word, the case is checked as in the dictionary
+func (s *GoSpell) spellWordForms(word string, wfs []WordForm) bool {
+	for _, wf := range wfs {
+		if wf.Forbidden {
+			continue
+		}
+		if wf.Flags != "" && hasFlag(parseFlagString(wf.Flags), s.Config.CompoundOnlyFlag) {
+			// только в составе сложных слов
+			continue
+		}
+		if wf.Original == "" && wf.Case == Mixed {
+			// таблица создана до учета регистра
+			return true
+		}
+		for _, variant := range s.Config.CaseVariations(wf.original(), wf.KeepCase) {
+			if variant == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newWordForm returns the stored word form of form, false if the
+// form is not needed
+func (s *GoSpell) newWordForm(form Form, lang string) (WordForm, bool) {
+	a := &s.Config
+	compoundPart := a.isCompoundPart(form.Flags) || s.isRulePart(form)
+	if hasFlag(form.Flags, a.CompoundOnlyFlag) && !compoundPart {
+		return WordForm{}, false
+	}
+	wf := WordForm{
+		Word:      strings.ToLower(form.Word),
+		Lang:      lang,
+		Case:      CaseStyle(form.Word),
+		KeepCase:  hasFlag(form.Flags, a.KeepCaseFlag),
+		Forbidden: hasFlag(form.Flags, a.ForbiddenFlag),
+		NoSuggest: hasFlag(form.Flags, a.NoSuggestFlag),
+	}
+	if wf.Case == Mixed {
+		wf.Original = form.Word
+	}
+	if s.phonetic != nil {
+		wf.Phonetic = s.phonetic(wf.Word)
+	}
+	if compoundPart {
+		wf.Flags = flagString(form.Flags)
+		wf.Prefixed = form.Prefixed
+		wf.Suffixed = form.Suffixed
+	}
+	return wf, true
+}
+
 // NewGoSpellReader создает GoSpell из файлов Huspell, переданных, как io.Reader
 // Если db передано не как nil, собирается таблица словоформ,
+// иначе в памяти хранятся только основы слов.  Отсечение аффиксов
+// работает только в памяти: в таблицу записываются все словоформы,
+// что для en_US занимает около 2 с
 func NewGoSpellReader(aff, dic io.Reader, db *gorm.DB, lang string) (*GoSpell, error) {
 	affix, err := NewDictConfig(aff)
 	if err != nil {
 		return nil, err
 	}
-
-	gs := GoSpell{
-		compounds: make([]*regexp.Regexp, 0, len(affix.CompoundRule)),
-		splitter:  NewSplitter(affix.WordChars),
+	if affix.Lang == "" {
+		affix.Lang = lang
 	}
 
-	words := []string{}
+	gs := NewGoSpellStore(affix, nil)
+	forms := []Form{}
 	wordForms := []WordForm{}
 
+	dic, err = affix.DecodeReader(dic)
+	if err != nil {
+		return nil, err
+	}
 	scanner := bufio.NewScanner(dic)
 
 	if !scanner.Scan() {
 		return nil, scanner.Err()
 	}
-	line := scanner.Text()
-	i, err := strconv.ParseInt(line, 10, 64)
+	line := strings.TrimPrefix(scanner.Text(), utf8BOM)
+	i, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
+	// in memory only the stems are stored
+	var stems *memoryStore
 	if db == nil {
-		gs.Dict = make(map[string]struct{}, i*5)
+		stems = newMemoryStore(affix, make(stemMap, i))
 	}
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		words, err = affix.Expand(line, words)
+		if stems != nil {
+			if err := stems.addLine(line); err != nil {
+				return nil, fmt.Errorf("Unable to process %q: %s", line, err)
+			}
+			continue
+		}
+
+		forms, err = affix.ExpandForms(line, forms)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to process %q: %s", line, err)
 		}
 
-		if len(words) == 0 {
+		if len(forms) == 0 {
 			continue
 		}
 
-		style := CaseStyle(words[0])
-		for _, word := range words {
-			if db != nil {
-				st := style
-				if st != Mixed && st != AllUpper && st != Title {
-					st = Mixed
-				}
-				wordForms = append(wordForms, WordForm{
-					Word: strings.ToLower(word),
-					Lang: lang,
-					Case: st,
-				})
-			} else {
-				for _, wordform := range CaseVariations(word, style) {
-					gs.Dict[wordform] = struct{}{}
-				}
+		for _, form := range forms {
+			if wf, ok := gs.newWordForm(form, lang); ok {
+				wordForms = append(wordForms, wf)
 			}
 		}
 	}
@@ -271,50 +510,27 @@ func NewGoSpellReader(aff, dic io.Reader, db *gorm.DB, lang string) (*GoSpell, e
 		return nil, err
 	}
 
-	for _, compoundRule := range affix.CompoundRule {
-		pattern := "^"
-		for _, key := range compoundRule {
-			switch key {
-			case '(', ')', '+', '?', '*':
-				pattern = pattern + string(key)
-			default:
-				groups := affix.CompoundMap[key]
-				pattern = pattern + "(" + strings.Join(groups, "|") + ")"
-			}
-		}
-		pattern = pattern + "$"
-		pat, err := regexp.Compile(pattern)
-		if err != nil {
-			log.Printf("REGEXP FAIL= %q %s", pattern, err)
-		} else {
-			gs.compounds = append(gs.compounds, pat)
-		}
+	if stems != nil {
+		gs.Store = stems
+		return gs, nil
 	}
 
-	if len(affix.IconvReplacements) > 0 {
-		gs.ireplacer = strings.NewReplacer(affix.IconvReplacements...)
+	gs.Store = NewGormStore(db)
+	if err := gs.Store.Add(wordForms...); err != nil {
+		return nil, err
 	}
-
-	if db != nil {
-		result := db.Create(&wordForms)
+	if cfg, err := json.Marshal(affix); err == nil {
+		var configs []Preferences
+		configs = append(configs, Preferences{
+			Dict: string(cfg),
+		})
+		result := db.Create(&configs)
 		if result.Error != nil {
 			return nil, result.Error
 		}
-
-		if cfg, err := json.Marshal(gs.Dict); err == nil {
-			var configs []Preferences
-			configs = append(configs, Preferences{
-				Dict: string(cfg),
-			})
-			result = db.Create(&configs)
-			if result.Error != nil {
-				return nil, result.Error
-			}
-		}
-
 	}
 	gs.DB = db
-	return &gs, nil
+	return gs, nil
 }
 
 // NewGoSpell создает новый GoSpell из файлов AFF, DIC Hunspell
@@ -329,7 +545,7 @@ func NewGoSpell(affFile, dicFile string) (*GoSpell, error) {
 		return nil, fmt.Errorf("Unable to open dic: %s", err)
 	}
 	defer dic.Close()
-	h, err := NewGoSpellReader(aff, dic, nil, "")
+	h, err := NewGoSpellReader(aff, dic, nil, fileNameWithoutExtTrimSuffix(filepath.Base(dicFile)))
 	return h, err
 }
 
@@ -365,7 +581,7 @@ func fileNameWithoutExtTrimSuffix(fileName string) string {
 
 func createTable(dbFile string, force bool, config *gorm.Config) *gorm.DB {
 	if force {
-		if _, err := os.Stat(dbFile); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(dbFile); !errors.Is(err, os.ErrNotExist) {
 			if err := os.Remove(dbFile); err != nil {
 				return nil
 			}
@@ -409,6 +625,30 @@ func createTable(dbFile string, force bool, config *gorm.Config) *gorm.DB {
 	return db
 }
 
+// migrateDB adds the columns of WordForm missing in the word form
+// table of an older version.  The phonetic codes of its words are
+// computed once, when the Phonetic column is added
+func migrateDB(db *gorm.DB, encode func(word string) string) error {
+	hadPhonetic := db.Migrator().HasColumn(&WordForm{}, "Phonetic")
+	if err := db.AutoMigrate(&WordForm{}); err != nil {
+		return err
+	}
+	if hadPhonetic || encode == nil {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var batch []WordForm
+		return tx.Select("id", "word").FindInBatches(&batch, 1000, func(_ *gorm.DB, _ int) error {
+			for _, wf := range batch {
+				if err := tx.Model(&wf).Update("phonetic", encode(wf.Word)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	})
+}
+
 // NewGoSpellDB создает GoSpell с использованием указанной в пути базы данных
 func NewGoSpellDB(dbFile string, config *gorm.Config) (*GoSpell, error) {
 	db := createTable(dbFile, false, config)
@@ -416,7 +656,9 @@ func NewGoSpellDB(dbFile string, config *gorm.Config) (*GoSpell, error) {
 	return h, err
 }
 
-// NewGoSpellDBReader создает GoSpell с использованием указанной базы данных
+// NewGoSpellDBReader создает GoSpell с использованием указанной базы
+// данных.  В таблицу словоформ старой версии добавляются недостающие
+// столбцы, см. migrateDB
 func NewGoSpellDBReader(db *gorm.DB) (*GoSpell, error) {
 	var prefs Preferences
 	db.First(&prefs)
@@ -424,36 +666,134 @@ func NewGoSpellDBReader(db *gorm.DB) (*GoSpell, error) {
 		return nil, errors.New("Not found Dict in preferences")
 	}
 	var affix *DictConfig
-	json.Unmarshal([]byte(prefs.Dict), &affix)
+	if err := json.Unmarshal([]byte(prefs.Dict), &affix); err != nil || affix == nil {
+		return nil, fmt.Errorf("Unable to read Dict from preferences: %v", err)
+	}
+	if err := migrateDB(db, affix.phoneticEncoder()); err != nil {
+		return nil, err
+	}
+	gs := NewGoSpellStore(affix, NewGormStore(db))
+	gs.DB = db
+	return gs, nil
+}
+
+// NewGoSpellStore создает GoSpell со словоформами из store
+func NewGoSpellStore(affix *DictConfig, store WordStore) *GoSpell {
 	gs := GoSpell{
-		compounds: make([]*regexp.Regexp, 0, len(affix.CompoundRule)),
-		splitter:  NewSplitter(affix.WordChars),
-	}
-
-	for _, compoundRule := range affix.CompoundRule {
-		pattern := "^"
-		for _, key := range compoundRule {
-			switch key {
-			case '(', ')', '+', '?', '*':
-				pattern = pattern + string(key)
-			default:
-				groups := affix.CompoundMap[key]
-				pattern = pattern + "(" + strings.Join(groups, "|") + ")"
-			}
-		}
-		pattern = pattern + "$"
-		pat, err := regexp.Compile(pattern)
-		if err != nil {
-			log.Printf("REGEXP FAIL= %q %s", pattern, err)
-		} else {
-			gs.compounds = append(gs.compounds, pat)
-		}
+		Config:   *affix,
+		Dict:     make(map[string]struct{}),
+		Store:    store,
+		splitter: NewSplitter(affix.WordChars),
+		rules:    compileCompoundRules(affix),
+		phonetic: affix.phoneticEncoder(),
 	}
 
 	if len(affix.IconvReplacements) > 0 {
 		gs.ireplacer = strings.NewReplacer(affix.IconvReplacements...)
 	}
+	if len(affix.OconvReplacements) > 0 {
+		gs.oreplacer = strings.NewReplacer(affix.OconvReplacements...)
+	}
+	return &gs
+}
 
-	gs.DB = db
-	return &gs, nil
+// Close releases the store, e.g. the word set of NewGoSpellWordSet
+func (s *GoSpell) Close() error {
+	if closer, ok := s.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// WriteWordSet expands the Hunspell files into the word set read by
+// NewGoSpellWordSet
+//
+//	Building is slow for large dictionaries, about 10 s for the French
+//	one, so write the word set once and open it afterwards
+func WriteWordSet(aff, dic io.Reader, out io.Writer) error {
+	affix, err := NewDictConfig(aff)
+	if err != nil {
+		return err
+	}
+	gs := NewGoSpellStore(affix, nil)
+	dic, err = affix.DecodeReader(dic)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(dic)
+
+	// the first line is the word count
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+
+	b := WordSetBuilder{}
+	var forms []Form
+	for scanner.Scan() {
+		line := scanner.Text()
+		forms, err = affix.ExpandForms(line, forms)
+		if err != nil {
+			return fmt.Errorf("Unable to process %q: %s", line, err)
+		}
+		for _, form := range forms {
+			if wf, ok := gs.newWordForm(form, ""); ok {
+				b.Add(wf.Word, encodeWordForm(wf))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	cfg, err := json.Marshal(affix)
+	if err != nil {
+		return err
+	}
+	_, err = b.Build(cfg).WriteTo(out)
+	return err
+}
+
+// NewGoSpellWordSetForce создает из файлов AFF, DIC Hunspell файл
+// словоформ wordSetFile и открывает его, как NewGoSpellWordSet.
+// Файл создается заново при каждом вызове, см. WriteWordSet
+func NewGoSpellWordSetForce(affFile, dicFile, wordSetFile string) (*GoSpell, error) {
+	aff, err := os.Open(affFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open aff: %s", err)
+	}
+	defer aff.Close()
+	dic, err := os.Open(dicFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open dic: %s", err)
+	}
+	defer dic.Close()
+
+	out, err := os.Create(wordSetFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteWordSet(aff, dic, out); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return NewGoSpellWordSet(wordSetFile)
+}
+
+// NewGoSpellWordSet создает GoSpell со словоформами из файла,
+// записанного WriteWordSet.  Файл отображается в память и
+// разделяется между процессами
+func NewGoSpellWordSet(wordSetFile string) (*GoSpell, error) {
+	ws, err := OpenWordSet(wordSetFile)
+	if err != nil {
+		return nil, err
+	}
+	var affix *DictConfig
+	if err := json.Unmarshal(ws.Meta(), &affix); err != nil || affix == nil {
+		ws.Close()
+		return nil, fmt.Errorf("Unable to read Dict from word set: %v", err)
+	}
+	return NewGoSpellStore(affix, NewWordSetStore(ws)), nil
 }
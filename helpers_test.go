@@ -0,0 +1,47 @@
+package gospell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestSpellers writes the .aff and .dic files in a temporary
+// directory and returns the spellers of their memory, database and
+// word set stores
+func newTestSpellers(t *testing.T, aff, dic string) []*GoSpell {
+	t.Helper()
+	dir := t.TempDir()
+	affFile, dicFile := filepath.Join(dir, "xx.aff"), filepath.Join(dir, "xx.dic")
+	writeFile(t, affFile, aff)
+	writeFile(t, dicFile, dic)
+
+	mem, err := NewGoSpell(affFile, dicFile)
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	db, err := NewGoSpellDBForce(affFile, dicFile, filepath.Join(dir, "xx.db"), nil)
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	ws, err := NewGoSpellWordSetForce(affFile, dicFile, filepath.Join(dir, "xx.dawg"))
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return []*GoSpell{mem, db, ws}
+}
+
+// storeName names the store of gs in the test errors
+func storeName(gs *GoSpell) string {
+	return reflect.TypeOf(gs.Store).String()
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(strings.TrimLeft(content, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
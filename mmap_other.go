@@ -0,0 +1,14 @@
+//go:build !unix
+
+package gospell
+
+import "os"
+
+// mmapFile reads the file where memory mapping is not available
+func mmapFile(name string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
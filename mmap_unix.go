@@ -0,0 +1,31 @@
+//go:build unix
+
+package gospell
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the file read only and shared between processes
+func mmapFile(name string) ([]byte, func() error, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fd.Close()
+	st, err := fd.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := st.Size()
+	if size == 0 || int64(int(size)) != size {
+		return nil, nil, errors.New("invalid file size")
+	}
+	data, err := syscall.Mmap(int(fd.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
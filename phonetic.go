@@ -0,0 +1,333 @@
+package gospell
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PhoneticStore is a WordStore able to find words by how they sound
+//
+//	The codes are made by the PHONE table of the dictionary or the
+//	built-in metaphone of its language, GoSpell stores them in
+//	WordForm.Phonetic.  The memory store codes the dictionary stems
+//	only, the suggestions find its affixed forms by the stem left
+//	when an affix is removed from the misspelled word.  The word set
+//	store is not a PhoneticStore, it has no phonetic suggestions
+type PhoneticStore interface {
+	WordStore
+	// Phonetic calls fn with the word forms of the phonetic code
+	// until fn returns false
+	Phonetic(code string, fn func(wf WordForm) bool) error
+}
+
+// phoneRule is a compiled PHONE rule
+//
+//	Only a subset of the aspell phonet rules is supported: letters,
+//	one group of letters in parentheses, "-" for the letters matched
+//	but not replaced, "<" to replace and match again, "^" and "$" for
+//	the start and the end of the word.  Priorities are ignored and
+//	"_" is the empty replacement
+type phoneRule struct {
+	letters []rune
+	group   []rune
+	ahead   int // letters matched but not replaced
+	again   bool
+	start   bool
+	end     bool
+	to      []rune
+}
+
+// compilePhoneRule parses a PHONE rule
+func compilePhoneRule(pattern, to string) phoneRule {
+	r := phoneRule{}
+	if to != "_" {
+		r.to = []rune(to)
+	}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '(':
+			for i++; i < len(runes) && runes[i] != ')'; i++ {
+				r.group = append(r.group, runes[i])
+			}
+		case c == '-':
+			r.ahead++
+		case c == '<':
+			r.again = true
+		case c == '^':
+			r.start = true
+		case c == '$':
+			r.end = true
+		case unicode.IsDigit(c):
+		default:
+			r.letters = append(r.letters, c)
+		}
+	}
+	return r
+}
+
+// match returns the number of letters of word matched by the rule at i
+func (r *phoneRule) match(word []rune, i int) (int, bool) {
+	if r.start && i > 0 {
+		return 0, false
+	}
+	n := len(r.letters)
+	if i+n > len(word) {
+		return 0, false
+	}
+	for k, c := range r.letters {
+		if word[i+k] != c {
+			return 0, false
+		}
+	}
+	if r.group != nil {
+		if i+n >= len(word) || !strings.ContainsRune(string(r.group), word[i+n]) {
+			return 0, false
+		}
+		n++
+	}
+	if r.end && i+n != len(word) {
+		return 0, false
+	}
+	return n, n > 0
+}
+
+// phonet encodes the upper case word with the PHONE rules, the first
+// matching rule wins and the letters matched by none are kept
+func phonet(rules []phoneRule, word string) string {
+	runes := []rune(strings.ToUpper(word))
+	out := make([]rune, 0, len(runes))
+	// "<" rules must not loop forever
+	limit := len(runes)
+	for i, again := 0, 0; i < len(runes); {
+		matched := false
+		for k := range rules {
+			r := &rules[k]
+			n, ok := r.match(runes, i)
+			if !ok {
+				continue
+			}
+			replaced := n - r.ahead
+			if replaced < 1 {
+				replaced = 1
+			}
+			if r.again && again < limit {
+				again++
+				runes = append(append(append([]rune{}, runes[:i]...), r.to...), runes[i+replaced:]...)
+			} else {
+				out = append(out, r.to...)
+				i += replaced
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			out = append(out, runes[i])
+			i++
+		}
+	}
+	return string(out)
+}
+
+// phoneticEncoder returns the phonetic code function of the
+// dictionary: the PHONE table, or the metaphone of English and
+// Russian, nil for other languages
+func (a *DictConfig) phoneticEncoder() func(word string) string {
+	if len(a.Phone) > 0 {
+		rules := make([]phoneRule, len(a.Phone))
+		for i, p := range a.Phone {
+			rules[i] = compilePhoneRule(p[0], p[1])
+		}
+		return func(word string) string {
+			return phonet(rules, word)
+		}
+	}
+	lang := strings.ToLower(a.Lang)
+	switch {
+	case strings.HasPrefix(lang, "en"):
+		return metaphone
+	case strings.HasPrefix(lang, "ru"):
+		return metaphoneRu
+	}
+	return nil
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("AEIOU", c) != -1
+}
+
+// metaphone is the original metaphone of Lawrence Philips for English
+//
+//	"0" stands for "th" and "X" for "sh"
+func metaphone(word string) string {
+	w := make([]byte, 0, len(word))
+	for _, c := range strings.ToUpper(word) {
+		// duplicated letters sound as one, except "CC"
+		if c >= 'A' && c <= 'Z' && (len(w) == 0 || w[len(w)-1] != byte(c) || c == 'C') {
+			w = append(w, byte(c))
+		}
+	}
+	if len(w) == 0 {
+		return ""
+	}
+	switch s := string(w); {
+	case strings.HasPrefix(s, "KN"), strings.HasPrefix(s, "GN"), strings.HasPrefix(s, "PN"),
+		strings.HasPrefix(s, "AE"), strings.HasPrefix(s, "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w[0] = 'S'
+	case strings.HasPrefix(s, "WH"):
+		w = append(w[:1], w[2:]...)
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	out := make([]byte, 0, len(w))
+	for i := 0; i < len(w); i++ {
+		c, prev, next := w[i], at(i-1), at(i+1)
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				out = append(out, c)
+			}
+		case 'B':
+			if !(prev == 'M' && i == len(w)-1) {
+				out = append(out, 'B')
+			}
+		case 'C':
+			switch {
+			case next == 'I' && at(i+2) == 'A', next == 'H':
+				if prev == 'S' && next == 'H' {
+					out = append(out, 'K')
+				} else {
+					out = append(out, 'X')
+				}
+			case next == 'I' || next == 'E' || next == 'Y':
+				if prev != 'S' {
+					out = append(out, 'S')
+				}
+			default:
+				out = append(out, 'K')
+			}
+		case 'D':
+			if next == 'G' && strings.IndexByte("EIY", at(i+2)) != -1 {
+				out = append(out, 'J')
+			} else {
+				out = append(out, 'T')
+			}
+		case 'G':
+			switch {
+			case next == 'H' && i+2 < len(w) && !isVowel(at(i+2)):
+			case next == 'N' && (i+2 == len(w) || string(w[i+1:]) == "NED"):
+			case prev == 'D' && strings.IndexByte("EIY", next) != -1:
+			case strings.IndexByte("EIY", next) != -1:
+				out = append(out, 'J')
+			default:
+				out = append(out, 'K')
+			}
+		case 'H':
+			if !(isVowel(prev) && !isVowel(next)) && strings.IndexByte("CSPTG", prev) == -1 {
+				out = append(out, 'H')
+			}
+		case 'K':
+			if prev != 'C' {
+				out = append(out, 'K')
+			}
+		case 'P':
+			if next == 'H' {
+				out = append(out, 'F')
+			} else {
+				out = append(out, 'P')
+			}
+		case 'Q':
+			out = append(out, 'K')
+		case 'S':
+			if next == 'H' || (next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A')) {
+				out = append(out, 'X')
+			} else {
+				out = append(out, 'S')
+			}
+		case 'T':
+			switch {
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				out = append(out, 'X')
+			case next == 'H':
+				out = append(out, '0')
+			case next == 'C' && at(i+2) == 'H':
+			default:
+				out = append(out, 'T')
+			}
+		case 'V':
+			out = append(out, 'F')
+		case 'W', 'Y':
+			if isVowel(next) {
+				out = append(out, c)
+			}
+		case 'X':
+			out = append(out, 'K', 'S')
+		case 'Z':
+			out = append(out, 'S')
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+// voiceless are the voiceless pairs of the Russian voiced consonants
+var voiceless = map[rune]rune{'Б': 'П', 'З': 'С', 'Д': 'Т', 'В': 'Ф', 'Г': 'К', 'Ж': 'Ш'}
+
+// metaphoneRu is the metaphone of Russian: unstressed vowels are
+// reduced, consonants devoiced before voiceless ones and at the end,
+// and duplicated letters sound as one
+func metaphoneRu(word string) string {
+	w := []rune(strings.ToUpper(word))
+	out := make([]rune, 0, len(w))
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		switch c {
+		case 'Ъ', 'Ь':
+			continue
+		case 'Й', 'И':
+			// "йо", "ио", "йе" and "ие" sound as "и"
+			if i+1 < len(w) && (w[i+1] == 'О' || w[i+1] == 'Е') {
+				i++
+			}
+			c = 'И'
+		case 'О', 'Ы', 'Я':
+			c = 'А'
+		case 'Е', 'Ё', 'Э':
+			c = 'И'
+		case 'Ю':
+			c = 'У'
+		case 'Т', 'Д':
+			if i+1 < len(w) && w[i+1] == 'С' {
+				c = 'Ц'
+				i++
+			}
+		}
+		if v, ok := voiceless[c]; ok {
+			next := rune(0)
+			for k := i + 1; k < len(w); k++ {
+				if w[k] != 'Ъ' && w[k] != 'Ь' {
+					next = w[k]
+					break
+				}
+			}
+			if next == 0 || strings.ContainsRune("ПСТФКШЦЧЩХ", next) {
+				c = v
+			}
+		}
+		if c < 'А' || c > 'Я' {
+			continue
+		}
+		if len(out) == 0 || out[len(out)-1] != c {
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
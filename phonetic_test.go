@@ -0,0 +1,103 @@
+package gospell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPhoneticEncoders(t *testing.T) {
+	for word, want := range map[string]string{
+		"phone":  "FN",
+		"fone":   "FN",
+		"knight": "NT",
+		"thumb":  "0M",
+		"school": "SKL",
+		"nation": "NXN",
+		"edge":   "EJ",
+		"Xavier": "SFR",
+	} {
+		if got := metaphone(word); got != want {
+			t.Errorf("metaphone %s want %s got %s", word, want, got)
+		}
+	}
+	for _, words := range [][]string{
+		{"молоко", "малако"},
+		{"дуб", "дуп"},
+		{"ёжик", "ёжек"},
+		{"лодка", "лотка"},
+	} {
+		if a, b := metaphoneRu(words[0]), metaphoneRu(words[1]); a != b {
+			t.Errorf("metaphoneRu %s %s and %s %s differ", words[0], a, words[1], b)
+		}
+	}
+
+	aff, err := NewDictConfig(strings.NewReader(`
+PHONE 4
+PHONE PH F
+PHONE AH(AEIOUY)-^ *H
+PHONE E$ _
+PHONE CK< K
+`))
+	if err != nil {
+		t.Fatalf("Unable to parse PHONE: %s", err)
+	}
+	encode := aff.phoneticEncoder()
+	for word, want := range map[string]string{"phone": "FON", "ahead": "*HEAD", "back": "BAK", "bake": "BAK"} {
+		if got := encode(word); got != want {
+			t.Errorf("PHONE %s want %s got %s", word, want, got)
+		}
+	}
+}
+
+func TestSuggestPhonetic(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+LANG en_US
+
+SFX S Y 1
+SFX S 0 s .
+`
+	sampleDic := `2
+phone/S
+night/S
+`
+	for _, gs := range newTestSpellers(t, sampleAff, sampleDic) {
+		name := storeName(gs)
+		// the word set store has no phonetic codes
+		if _, ok := gs.Store.(PhoneticStore); !ok {
+			continue
+		}
+		for word, want := range map[string]string{"fone": "phone", "nite": "night", "fones": "phones", "nites": "nights"} {
+			got, err := gs.SuggestDetailed(word, 0)
+			if err != nil || len(got) != 1 || got[0].Word != want || got[0].Score != 1.0/3 || got[0].Generator != GeneratorPhonetic {
+				t.Errorf("%s: %s want %s from phonetic got %+v %v", name, word, want, got, err)
+			}
+		}
+	}
+}
+
+func TestSuggestPhoneticCase(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY esianrtolcdugmphbyfvkw
+LANG en_US
+`
+	sampleDic := `6
+the
+tea
+ten
+DE
+DEA
+Dee
+`
+	gs, err := NewGoSpellReader(strings.NewReader(sampleAff), strings.NewReader(sampleDic), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	// sound alike words of another case rank after the typos
+	want := []string{"the", "tea", "ten", "Dee", "DE", "DEA"}
+	if got, err := gs.Suggest("teh", 0); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("teh: want %v got %v %v", want, got, err)
+	}
+}
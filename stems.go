@@ -0,0 +1,440 @@
+package gospell
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// stem is a dictionary word with its flags
+type stem struct {
+	word  string
+	flags []rune
+}
+
+// stemTable finds the homonym stems by lower case stem
+type stemTable interface {
+	// homonyms returns the stems of the key
+	homonyms(key string) []stem
+	// each calls fn with every key and its stems until fn returns false
+	each(fn func(key string, homonyms []stem) bool)
+}
+
+// stemMap is the stemTable of the stems parsed from a .dic file
+type stemMap map[string][]stem
+
+func (m stemMap) homonyms(key string) []stem {
+	return m[key]
+}
+
+func (m stemMap) each(fn func(key string, homonyms []stem) bool) {
+	for key, homonyms := range m {
+		if !fn(key, homonyms) {
+			return
+		}
+	}
+}
+
+// affixRef is an affix rule with the flag and the cross product
+// setting of its affix
+type affixRef struct {
+	flag  rune
+	cross bool
+	strip string // lower case strip characters
+	rule  *Rule
+}
+
+// affixIndex finds the affix rules by their lower case affix text
+type affixIndex struct {
+	prefixes map[string][]affixRef
+	suffixes map[string][]affixRef
+}
+
+// newAffixIndex indexes the rules of every affix
+func newAffixIndex(a *DictConfig) *affixIndex {
+	idx := &affixIndex{
+		prefixes: make(map[string][]affixRef),
+		suffixes: make(map[string][]affixRef),
+	}
+	for flag, af := range a.AffixMap {
+		m := idx.suffixes
+		if af.Type == Prefix {
+			m = idx.prefixes
+		}
+		for i := range af.Rules {
+			key := strings.ToLower(af.Rules[i].AffixText)
+			m[key] = append(m[key], affixRef{flag: flag, cross: af.CrossProduct, strip: strings.ToLower(af.Rules[i].Strip), rule: &af.Rules[i]})
+		}
+	}
+	return idx
+}
+
+// continues returns true if the affixes removed before, outer, can
+// follow ref: the next one must be in its continuation class
+func (ref affixRef) continues(outer []affixRef) bool {
+	return len(outer) == 0 || hasFlag(ref.rule.Flags, outer[0].flag)
+}
+
+// stripPrefixes calls fn with word and every word left by removing up
+// to depth prefixes, the removed rules are given inner first
+func (idx *affixIndex) stripPrefixes(word string, depth int, outer []affixRef, fn func(string, []affixRef) bool) bool {
+	if fn(word, outer) {
+		return true
+	}
+	if depth == 0 {
+		return false
+	}
+	for end := 0; end <= len(word); end++ {
+		if end < len(word) && !utf8.RuneStart(word[end]) {
+			continue
+		}
+		for _, ref := range idx.prefixes[word[:end]] {
+			if !ref.continues(outer) {
+				continue
+			}
+			rest := ref.strip + word[end:]
+			if rest == "" {
+				continue
+			}
+			refs := append([]affixRef{ref}, outer...)
+			if idx.stripPrefixes(rest, depth-1, refs, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripSuffixes is stripPrefixes for suffixes
+func (idx *affixIndex) stripSuffixes(word string, depth int, outer []affixRef, fn func(string, []affixRef) bool) bool {
+	if fn(word, outer) {
+		return true
+	}
+	if depth == 0 {
+		return false
+	}
+	for start := len(word); start >= 0; start-- {
+		if start < len(word) && !utf8.RuneStart(word[start]) {
+			continue
+		}
+		for _, ref := range idx.suffixes[word[start:]] {
+			if !ref.continues(outer) {
+				continue
+			}
+			rest := word[:start] + ref.strip
+			if rest == "" {
+				continue
+			}
+			refs := append([]affixRef{ref}, outer...)
+			if idx.stripSuffixes(rest, depth-1, refs, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applySuffixes applies the suffixes, inner first, to the parent form
+// the way suffixForms does.  The first suffix must be one of allowed,
+// the next ones in the continuation class of the previous one
+func (a DictConfig) applySuffixes(parent affixed, allowed []rune, suf []affixRef) (affixed, bool) {
+	for _, ref := range suf {
+		if !hasFlag(allowed, ref.flag) {
+			return parent, false
+		}
+		w, ok := ref.rule.apply(Suffix, parent.word, a.FullStrip)
+		if !ok {
+			return parent, false
+		}
+		f := a.ruleState(*ref.rule)
+		f.word = w
+		f.cross = parent.cross && ref.cross
+		f.suffixed = true
+		f.flags = joinFlags(parent.flags, ref.rule.Flags)
+		f.circumfix = f.circumfix || parent.circumfix
+		parent, allowed = f, ref.rule.Flags
+	}
+	return parent, true
+}
+
+// generate applies the prefixes and suffixes, inner first, to the stem
+// and returns the word form if ExpandForms would generate it
+func (a DictConfig) generate(st stem, pre, suf []affixRef) (Form, bool) {
+	keys := st.flags
+	if len(pre) == 0 && len(suf) == 0 {
+		return Form{Word: st.word, Flags: keys}, !hasFlag(keys, a.NeedAffixFlag)
+	}
+
+	// the bare stem has no affix to satisfy a NEEDAFFIX prefix
+	base := affixed{word: st.word, cross: true, needAffix: true}
+	if len(pre) == 0 {
+		f, ok := a.applySuffixes(base, keys, suf)
+		return Form{Word: f.word, Flags: joinFlags(keys, f.flags), Suffixed: true}, ok && f.valid()
+	}
+
+	// suffixes allowed by the prefix continuation class
+	if len(pre) == 1 && len(suf) > 0 && hasFlag(keys, pre[0].flag) {
+		if w, ok := pre[0].rule.apply(Prefix, st.word, a.FullStrip); ok {
+			f, ok := a.applySuffixes(affixed{word: w, cross: true}, pre[0].rule.Flags, suf)
+			if p := a.prefixed(f, *pre[0].rule); ok && p.valid() {
+				return Form{Word: f.word, Flags: joinFlags(keys, p.flags), Prefixed: true, Suffixed: true}, true
+			}
+		}
+	}
+
+	f := base
+	if len(suf) > 0 {
+		var ok bool
+		if f, ok = a.applySuffixes(base, keys, suf); !ok {
+			return Form{}, false
+		}
+	}
+
+	// the first prefix is a flag of the stem, in cross product with
+	// the suffixes, or comes from the suffix continuation classes
+	fromStem := hasFlag(keys, pre[0].flag) && (len(suf) == 0 || (pre[0].cross && f.cross))
+	if !fromStem && (len(suf) == 0 || !hasFlag(f.flags, pre[0].flag)) {
+		return Form{}, false
+	}
+	for i, ref := range pre {
+		if i > 0 && !hasFlag(pre[i-1].rule.Flags, ref.flag) {
+			return Form{}, false
+		}
+		w, ok := ref.rule.apply(Prefix, f.word, a.FullStrip)
+		if !ok {
+			return Form{}, false
+		}
+		f = a.prefixed(f, *ref.rule)
+		f.word = w
+	}
+	return Form{Word: f.word, Flags: joinFlags(keys, f.flags), Prefixed: true, Suffixed: len(suf) > 0}, f.valid()
+}
+
+// memoryStore is the in-memory WordStore of the dictionary stems,
+// word forms are found by removing affixes from the words looked up
+//
+//	The database and word set stores hold the expanded word forms
+//	instead, their stems are not stored
+type memoryStore struct {
+	overlay
+	config  *DictConfig
+	stems   stemTable
+	affixes *affixIndex
+
+	phoneticOnce sync.Once
+	encode       func(word string) string // phonetic code
+	phonetics    map[string][]string      // phonetic code to stems, see Phonetic
+}
+
+// NewMemoryStore returns an empty in-memory store for the affixes
+// of config
+func NewMemoryStore(config *DictConfig) WordStore {
+	return newMemoryStore(config, stemMap{})
+}
+
+func newMemoryStore(config *DictConfig, stems stemTable) *memoryStore {
+	return &memoryStore{
+		config:  config,
+		stems:   stems,
+		affixes: newAffixIndex(config),
+	}
+}
+
+// addLine adds a .dic line to the stems parsed
+func (ms *memoryStore) addLine(line string) error {
+	word, keys, err := ms.config.parseDicLine(line)
+	if err != nil || word == "" {
+		return err
+	}
+	stems := ms.stems.(stemMap)
+	lower := strings.ToLower(word)
+	stems[lower] = append(stems[lower], stem{word: word, flags: keys})
+	return nil
+}
+
+// wordForm returns the stored word form of form with its flags
+func (ms *memoryStore) wordForm(form Form) WordForm {
+	a := ms.config
+	wf := WordForm{
+		Word:      strings.ToLower(form.Word),
+		Case:      CaseStyle(form.Word),
+		KeepCase:  hasFlag(form.Flags, a.KeepCaseFlag),
+		Forbidden: hasFlag(form.Flags, a.ForbiddenFlag),
+		NoSuggest: hasFlag(form.Flags, a.NoSuggestFlag),
+		Flags:     flagString(form.Flags),
+		Prefixed:  form.Prefixed,
+		Suffixed:  form.Suffixed,
+	}
+	if wf.Case == Mixed {
+		wf.Original = form.Word
+	}
+	return wf
+}
+
+// Lookup removes affixes from the word and returns the word forms
+// generated from the stems left
+func (ms *memoryStore) Lookup(word string) ([]WordForm, error) {
+	a := ms.config
+	wfs := append([]WordForm(nil), ms.forms[word]...)
+	if ms.hidden(word) {
+		return wfs, nil
+	}
+
+	// twofold suffixes, or twofold prefixes with COMPLEXPREFIXES
+	suffixDepth, prefixDepth := maxSuffixes, 1
+	if a.ComplexPrefixes {
+		suffixDepth, prefixDepth = 1, maxSuffixes
+	}
+	ms.affixes.stripPrefixes(word, prefixDepth, nil, func(rest string, pre []affixRef) bool {
+		return ms.affixes.stripSuffixes(rest, suffixDepth, nil, func(candidate string, suf []affixRef) bool {
+			for _, st := range ms.stems.homonyms(candidate) {
+				form, ok := a.generate(st, pre, suf)
+				if !ok || strings.ToLower(form.Word) != word {
+					continue
+				}
+				wf := ms.wordForm(form)
+				if !containsWordForm(wfs, wf) {
+					wfs = append(wfs, wf)
+				}
+			}
+			return false
+		})
+	})
+	return wfs, nil
+}
+
+// containsWordForm returns true if wf is one of wfs
+func containsWordForm(wfs []WordForm, wf WordForm) bool {
+	for _, other := range wfs {
+		if other == wf {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *memoryStore) Add(forms ...WordForm) error {
+	ms.add(forms)
+	return nil
+}
+
+// Remove deletes the word forms added and hides the forms made from
+// the stems spelled as word, the other forms of the stems are kept
+func (ms *memoryStore) Remove(word string) error {
+	ms.remove(word)
+	return nil
+}
+
+// Enumerate expands every stem at each call, about 0.8 s for en_GB and
+// 5 s for fr, nothing is cached to keep the memory of the stems only
+func (ms *memoryStore) Enumerate(fn func(wf WordForm) bool) error {
+	if !ms.enumerate(fn) {
+		return nil
+	}
+	var forms []Form
+	ms.stems.each(func(_ string, homonyms []stem) bool {
+		for _, st := range homonyms {
+			forms = ms.config.expandStem(st.word, st.flags, forms)
+			for _, form := range forms {
+				wf := ms.wordForm(form)
+				if !ms.hidden(wf.Word) && !fn(wf) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+// Close unmaps the stems of a compiled dictionary
+func (ms *memoryStore) Close() error {
+	if m, ok := ms.stems.(*mappedStems); ok {
+		return m.close()
+	}
+	return nil
+}
+
+// phoneticIndex builds the index of the stems by phonetic code at
+// the first call, false without phonetic encoder
+func (ms *memoryStore) phoneticIndex() bool {
+	ms.phoneticOnce.Do(func() {
+		ms.encode = ms.config.phoneticEncoder()
+		if ms.encode == nil {
+			return
+		}
+		ms.phonetics = make(map[string][]string)
+		ms.stems.each(func(key string, _ []stem) bool {
+			c := ms.encode(key)
+			ms.phonetics[c] = append(ms.phonetics[c], key)
+			return true
+		})
+	})
+	return ms.encode != nil
+}
+
+// Phonetic finds the stems and the word forms added sounding as
+// code, the index of the stems is built at the first call
+//
+//	The forms made with affixes are not indexed to keep the memory
+//	of the stems only, see phoneticAffixed
+func (ms *memoryStore) Phonetic(code string, fn func(wf WordForm) bool) error {
+	if !ms.phoneticIndex() {
+		return nil
+	}
+	for _, wfs := range ms.forms {
+		for _, wf := range wfs {
+			if ms.encode(wf.Word) == code && !fn(wf) {
+				return nil
+			}
+		}
+	}
+	ms.phoneticStems(code, nil, nil, fn)
+	return nil
+}
+
+// phoneticStems calls fn with the forms of the stems sounding as code
+// made with the prefixes pre and the suffixes suf, false if fn stopped
+func (ms *memoryStore) phoneticStems(code string, pre, suf []affixRef, fn func(wf WordForm) bool) bool {
+	for _, key := range ms.phonetics[code] {
+		for _, st := range ms.stems.homonyms(key) {
+			form, ok := ms.config.generate(st, pre, suf)
+			if !ok {
+				continue
+			}
+			wf := ms.wordForm(form)
+			if !ms.hidden(wf.Word) && !fn(wf) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// phoneticAffixed finds the affixed forms sounding as the lower case
+// word: a prefix or a suffix is removed from the word and the stems
+// sounding as the rest get it back, "phones" for "fones"
+func (ms *memoryStore) phoneticAffixed(word string, fn func(wf WordForm) bool) {
+	if !ms.phoneticIndex() {
+		return
+	}
+	stopped := ms.affixes.stripSuffixes(word, 1, nil, func(rest string, suf []affixRef) bool {
+		return len(suf) > 0 && !ms.phoneticStems(ms.encode(rest), nil, suf, fn)
+	})
+	if !stopped {
+		ms.affixes.stripPrefixes(word, 1, nil, func(rest string, pre []affixRef) bool {
+			return len(pre) > 0 && !ms.phoneticStems(ms.encode(rest), pre, nil, fn)
+		})
+	}
+}
+
+// Fuzzy compares the word with every word form of Enumerate, it is
+// slow.  The suggestions of the memory store do not use it
+func (ms *memoryStore) Fuzzy(word string, maxDist int, fn func(wf WordForm, dist int) bool) error {
+	return ms.Enumerate(func(wf WordForm) bool {
+		if dist, ok := withinDistance(word, wf.Word, maxDist); ok {
+			return fn(wf, dist)
+		}
+		return true
+	})
+}
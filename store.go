@@ -0,0 +1,387 @@
+package gospell
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// WordStore holds the word forms of a dictionary
+//
+//	Words are stored and looked up in lower case, the WordForm
+//	tells how they may be capitalized.  GoSpell works the same with
+//	every store, see NewGoSpellStore to use your own
+type WordStore interface {
+	// Lookup returns the word forms of the lower case word
+	Lookup(word string) ([]WordForm, error)
+	// Add stores the word forms
+	Add(forms ...WordForm) error
+	// Remove deletes the word forms of the lower case word, the other
+	// forms of the same stem, as "walks" for "walk", are kept
+	Remove(word string) error
+	// Enumerate calls fn with every word form until fn returns false
+	Enumerate(fn func(wf WordForm) bool) error
+	// Fuzzy calls fn with the word forms of the words within maxDist
+	// edits of the lower case word, see editDistance, until fn
+	// returns false
+	Fuzzy(word string, maxDist int, fn func(wf WordForm, dist int) bool) error
+}
+
+// BatchStore is a WordStore able to look up many words at once, the
+// suggestions check their candidates with it instead of one Lookup
+// each
+type BatchStore interface {
+	WordStore
+	// LookupAll returns the word forms of the lower case words by
+	// word, the words not found are missing
+	LookupAll(words []string) (map[string][]WordForm, error)
+}
+
+// errStop stops the enumerations of the stores
+var errStop = errors.New("stop")
+
+// editDistance is the optimal string alignment distance of a and b:
+// the number of characters inserted, deleted, replaced or swapped
+// with the next one
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	row := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min3(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < row[j] {
+				row[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, row = prev, row, prev2
+	}
+	return prev[len(rb)]
+}
+
+// withinDistance returns the distance of a and b if it is at most
+// maxDist, skipping words of a too different length
+func withinDistance(a, b string, maxDist int) (int, bool) {
+	diff := utf8.RuneCountInString(a) - utf8.RuneCountInString(b)
+	if diff > maxDist || -diff > maxDist {
+		return 0, false
+	}
+	dist := editDistance(a, b)
+	return dist, dist <= maxDist
+}
+
+// gormStore is the word form table of a database
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns the store of the WordForm table of db
+func NewGormStore(db *gorm.DB) WordStore {
+	return &gormStore{db: db}
+}
+
+func (gs *gormStore) Lookup(word string) ([]WordForm, error) {
+	var wfs []WordForm
+	result := gs.db.Where("word = ?", word).Find(&wfs)
+	return wfs, result.Error
+}
+
+// maxBatchWords is the number of words looked up by query, below the
+// 999 parameters of older SQLite versions
+const maxBatchWords = 500
+
+// LookupAll selects the words with IN queries of maxBatchWords words
+func (gs *gormStore) LookupAll(words []string) (map[string][]WordForm, error) {
+	found := make(map[string][]WordForm)
+	for len(words) > 0 {
+		n := len(words)
+		if n > maxBatchWords {
+			n = maxBatchWords
+		}
+		var wfs []WordForm
+		if err := gs.db.Where("word IN ?", words[:n]).Find(&wfs).Error; err != nil {
+			return found, err
+		}
+		for _, wf := range wfs {
+			found[wf.Word] = append(found[wf.Word], wf)
+		}
+		words = words[n:]
+	}
+	return found, nil
+}
+
+func (gs *gormStore) Add(forms ...WordForm) error {
+	if len(forms) == 0 {
+		return nil
+	}
+	return gs.db.Create(&forms).Error
+}
+
+func (gs *gormStore) Remove(word string) error {
+	return gs.db.Where("word = ?", word).Delete(&WordForm{}).Error
+}
+
+// enumerate calls fn with the word forms selected by tx in batches
+func (gs *gormStore) enumerate(tx *gorm.DB, fn func(wf WordForm) bool) error {
+	var batch []WordForm
+	result := tx.FindInBatches(&batch, 1000, func(tx *gorm.DB, n int) error {
+		for _, wf := range batch {
+			if !fn(wf) {
+				return errStop
+			}
+		}
+		return nil
+	})
+	if errors.Is(result.Error, errStop) {
+		return nil
+	}
+	return result.Error
+}
+
+func (gs *gormStore) Enumerate(fn func(wf WordForm) bool) error {
+	return gs.enumerate(gs.db.Model(&WordForm{}), fn)
+}
+
+// globEscaper escapes the GLOB wildcards of words
+var globEscaper = strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+
+// Fuzzy finds single edits with the word index, see fuzzyEdits, and
+// scans the words of a close length otherwise
+func (gs *gormStore) Fuzzy(word string, maxDist int, fn func(wf WordForm, dist int) bool) error {
+	if maxDist <= 1 {
+		return gs.fuzzyEdits(word, fn)
+	}
+	n := utf8.RuneCountInString(word)
+	tx := gs.db.Model(&WordForm{}).Where("length(word) BETWEEN ? AND ?", n-maxDist, n+maxDist)
+	return gs.enumerate(tx, func(wf WordForm) bool {
+		if dist, ok := withinDistance(word, wf.Word, maxDist); ok {
+			return fn(wf, dist)
+		}
+		return true
+	})
+}
+
+// fuzzyEdits selects the words keeping the first character of the
+// word by GLOB patterns of a fixed prefix, the others are looked up
+// with each first character of the table
+func (gs *gormStore) fuzzyEdits(word string, fn func(wf WordForm, dist int) bool) error {
+	firsts, err := gs.firstRunes()
+	if err != nil {
+		return err
+	}
+	runes := []rune(word)
+	words := []string{word}
+	for _, r := range firsts {
+		if len(runes) > 0 {
+			words = append(words, string(r)+string(runes[1:]))
+		}
+		words = append(words, string(r)+word)
+	}
+	escaped := make([]string, len(runes))
+	for i, r := range runes {
+		escaped[i] = globEscaper.Replace(string(r))
+	}
+	var where []string
+	var args []interface{}
+	for i := range runes {
+		// deleted and swapped characters
+		words = append(words, string(runes[:i])+string(runes[i+1:]))
+		if i > 0 {
+			words = append(words, string(runes[:i-1])+string(runes[i])+string(runes[i-1])+string(runes[i+1:]))
+		}
+		// replaced and inserted characters, after the first one
+		before := strings.Join(escaped[:i+1], "")
+		if i+1 < len(runes) {
+			where = append(where, "word GLOB ?")
+			args = append(args, before+"?"+strings.Join(escaped[i+2:], ""))
+		}
+		where = append(where, "word GLOB ?")
+		args = append(args, before+"?"+strings.Join(escaped[i+1:], ""))
+	}
+	found, err := gs.LookupAll(words)
+	if err != nil {
+		return err
+	}
+	var wfs []WordForm
+	if len(where) > 0 {
+		// not in batches, SQLite scans the table in the order of the
+		// batches instead of searching the index for each pattern
+		if err := gs.db.Where(strings.Join(where, " OR "), args...).Find(&wfs).Error; err != nil {
+			return err
+		}
+	}
+	for _, w := range words {
+		wfs = append(wfs, found[w]...)
+		delete(found, w)
+	}
+	seen := make(map[uint]bool)
+	for _, wf := range wfs {
+		if seen[wf.ID] {
+			continue
+		}
+		seen[wf.ID] = true
+		if dist, ok := withinDistance(word, wf.Word, 1); ok && !fn(wf, dist) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// firstRunes returns the first characters of the words, seeking the
+// word index once for each
+func (gs *gormStore) firstRunes() ([]rune, error) {
+	var firsts []rune
+	for next := "\x01"; ; {
+		var words []string
+		err := gs.db.Model(&WordForm{}).Where("word >= ?", next).Order("word").Limit(1).Pluck("word", &words).Error
+		if err != nil || len(words) == 0 {
+			return firsts, err
+		}
+		r, _ := utf8.DecodeRuneInString(words[0])
+		firsts = append(firsts, r)
+		if r+1 >= 0xD800 && r+1 < 0xE000 {
+			// no surrogate halves in UTF-8
+			r = 0xDFFF
+		}
+		next = string(r + 1)
+	}
+}
+
+// Phonetic selects the word forms by their Phonetic column
+func (gs *gormStore) Phonetic(code string, fn func(wf WordForm) bool) error {
+	return gs.enumerate(gs.db.Model(&WordForm{}).Where("phonetic = ?", code), fn)
+}
+
+// overlay holds the word forms added to a store whose own words are
+// not changed, and the words removed from them
+type overlay struct {
+	forms   map[string][]WordForm // word forms added with Add
+	removed map[string]struct{}   // words whose own forms are removed
+}
+
+func (o *overlay) add(forms []WordForm) {
+	if o.forms == nil {
+		o.forms = make(map[string][]WordForm)
+	}
+	for _, wf := range forms {
+		o.forms[wf.Word] = append(o.forms[wf.Word], wf)
+	}
+}
+
+func (o *overlay) remove(word string) {
+	if o.removed == nil {
+		o.removed = make(map[string]struct{})
+	}
+	delete(o.forms, word)
+	o.removed[word] = struct{}{}
+}
+
+// hidden returns true if the own forms of the word are removed
+func (o *overlay) hidden(word string) bool {
+	_, ok := o.removed[word]
+	return ok
+}
+
+// enumerate calls fn with the word forms added, false if fn stopped
+func (o *overlay) enumerate(fn func(wf WordForm) bool) bool {
+	for _, wfs := range o.forms {
+		for _, wf := range wfs {
+			if !fn(wf) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// wordSetStore is the store of a WordSet
+type wordSetStore struct {
+	overlay
+	ws *WordSet
+}
+
+// NewWordSetStore returns the store of the word forms of a word set
+// written by WriteWordSet.  Add and Remove only change the store in
+// memory, the word set is not written.  It is not a PhoneticStore
+func NewWordSetStore(ws *WordSet) WordStore {
+	return &wordSetStore{ws: ws}
+}
+
+func (ss *wordSetStore) Lookup(word string) ([]WordForm, error) {
+	wfs := append([]WordForm(nil), ss.forms[word]...)
+	if ss.hidden(word) {
+		return wfs, nil
+	}
+	ss.ws.Values(word, func(value string) bool {
+		wfs = append(wfs, decodeWordForm(word, value))
+		return true
+	})
+	return wfs, nil
+}
+
+func (ss *wordSetStore) Add(forms ...WordForm) error {
+	ss.add(forms)
+	return nil
+}
+
+func (ss *wordSetStore) Remove(word string) error {
+	ss.remove(word)
+	return nil
+}
+
+// values calls fn with the word forms of key in the word set
+func (ss *wordSetStore) values(key string, fn func(wf WordForm) bool) bool {
+	if ss.hidden(key) {
+		return true
+	}
+	more := true
+	ss.ws.Values(key, func(value string) bool {
+		more = fn(decodeWordForm(key, value))
+		return more
+	})
+	return more
+}
+
+func (ss *wordSetStore) Enumerate(fn func(wf WordForm) bool) error {
+	if !ss.enumerate(fn) {
+		return nil
+	}
+	ss.ws.Prefix("", func(key string) bool {
+		return ss.values(key, fn)
+	})
+	return nil
+}
+
+func (ss *wordSetStore) Fuzzy(word string, maxDist int, fn func(wf WordForm, dist int) bool) error {
+	more := ss.enumerate(func(wf WordForm) bool {
+		if dist, ok := withinDistance(word, wf.Word, maxDist); ok {
+			return fn(wf, dist)
+		}
+		return true
+	})
+	if !more {
+		return nil
+	}
+	ss.ws.Fuzzy(word, maxDist, func(key string, dist int) bool {
+		return ss.values(key, func(wf WordForm) bool {
+			return fn(wf, dist)
+		})
+	})
+	return nil
+}
+
+// Close closes the word set
+func (ss *wordSetStore) Close() error {
+	return ss.ws.Close()
+}
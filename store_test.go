@@ -0,0 +1,218 @@
+package gospell
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestWordStores(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY aeiklprsw
+FORBIDDENWORD F
+
+SFX S Y 1
+SFX S 0 s .
+`
+	sampleDic := `6
+walk/S
+Paris
+iPhone
+walks/F
+talk/S
+bark/S
+`
+	for _, gs := range newTestSpellers(t, sampleAff, sampleDic) {
+		name := storeName(gs)
+		store := gs.Store
+
+		wfs, err := store.Lookup("paris")
+		if err != nil || len(wfs) != 1 || wfs[0].Case != Title {
+			t.Errorf("%s: Lookup want Title paris got %+v %v", name, wfs, err)
+		}
+		wfs, _ = store.Lookup("iphone")
+		if len(wfs) != 1 || wfs[0].original() != "iPhone" {
+			t.Errorf("%s: Lookup want iPhone got %+v", name, wfs)
+		}
+
+		var words []string
+		store.Enumerate(func(wf WordForm) bool {
+			if !wf.Forbidden {
+				words = append(words, wf.original())
+			}
+			return true
+		})
+		sort.Strings(words)
+		if want := []string{"Paris", "bark", "barks", "iPhone", "talk", "talks", "walk", "walks"}; !reflect.DeepEqual(want, words) {
+			t.Errorf("%s: Enumerate want %v got %v", name, want, words)
+		}
+
+		words = words[:0]
+		store.Fuzzy("walsk", 1, func(wf WordForm, dist int) bool {
+			if !wf.Forbidden {
+				words = append(words, wf.Word)
+			}
+			return true
+		})
+		sort.Strings(words)
+		if want := []string{"walk", "walks"}; !reflect.DeepEqual(want, words) {
+			t.Errorf("%s: Fuzzy want %v got %v", name, want, words)
+		}
+
+		for word, want := range map[string]bool{"walk": true, "walks": false, "Paris": true, "paris": false, "IPHONE": true} {
+			if gs.Spell(word) != want {
+				t.Errorf("%s: %q was not %v", name, word, want)
+			}
+		}
+		want := []Suggestion{{"walk", 1.0 / 3, []Edit{{EditReplace, 3, "z", "k"}}, GeneratorTypo}}
+		if got, err := gs.SuggestDetailed("walz", MaxSuggestions); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: suggestions want %+v got %+v %v", name, want, got, err)
+		}
+
+		if err := store.Add(WordForm{Word: "gospell", Case: AllLower}); err != nil {
+			t.Errorf("%s: Add: %s", name, err)
+		}
+		if !gs.Spell("Gospell") {
+			t.Errorf("%s: added word not found", name)
+		}
+		// the other forms of the stems of the words removed are kept
+		for _, word := range []string{"talk", "barks"} {
+			if err := store.Remove(word); err != nil {
+				t.Errorf("%s: Remove: %s", name, err)
+			}
+		}
+		for word, want := range map[string]bool{"talk": false, "talks": true, "bark": true, "barks": false} {
+			if gs.Spell(word) != want {
+				t.Errorf("%s: after Remove %q was not %v", name, word, want)
+			}
+		}
+		words = words[:0]
+		store.Enumerate(func(wf WordForm) bool {
+			if strings.HasPrefix(wf.Word, "talk") || strings.HasPrefix(wf.Word, "bark") {
+				words = append(words, wf.Word)
+			}
+			return true
+		})
+		sort.Strings(words)
+		if want := []string{"bark", "talks"}; !reflect.DeepEqual(want, words) {
+			t.Errorf("%s: Enumerate after Remove want %v got %v", name, want, words)
+		}
+
+		// personal words are word forms of the store
+		if _, err := gs.AddWordList(strings.NewReader("Gophers\n*Paris\n")); err != nil {
+			t.Errorf("%s: AddWordList: %s", name, err)
+		}
+		if gs.AddWordRaw("Gophers") {
+			t.Errorf("%s: personal word added twice", name)
+		}
+		for word, want := range map[string]bool{"Gophers": true, "GOPHERS": true, "gophers": false, "Paris": false, "PARIS": false} {
+			if gs.Spell(word) != want {
+				t.Errorf("%s: personal %q was not %v", name, word, want)
+			}
+		}
+		wfs, _ = store.Lookup("paris")
+		if len(wfs) != 2 {
+			t.Errorf("%s: Lookup want dictionary and forbidden paris got %+v", name, wfs)
+		}
+	}
+}
+
+func TestGormStoreQueries(t *testing.T) {
+	sampleDic := `4
+walk
+o'clock
+snake_case
+100%
+`
+	dir := t.TempDir()
+	affFile, dicFile := filepath.Join(dir, "xx.aff"), filepath.Join(dir, "xx.dic")
+	writeFile(t, affFile, "SET UTF-8\nWORDCHARS '_%\n")
+	writeFile(t, dicFile, sampleDic)
+	// the closed database below is expected to log errors
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	gs, err := NewGoSpellDBForce(affFile, dicFile, filepath.Join(dir, "xx.db"), config)
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+
+	for word, want := range map[string]string{
+		"o'clok":                           "o'clock",
+		"snake_cas":                        "snake_case",
+		"snake%case":                       "snake_case",
+		"100!":                             "100%",
+		"walk'; DROP TABLE word_forms; --": "",
+	} {
+		var found []string
+		err := gs.Store.Fuzzy(word, 1, func(wf WordForm, dist int) bool {
+			found = append(found, wf.Word)
+			return true
+		})
+		if err != nil {
+			t.Errorf("%s: Fuzzy: %s", word, err)
+		}
+		if want == "" && len(found) != 0 || want != "" && !reflect.DeepEqual(found, []string{want}) {
+			t.Errorf("%s: Fuzzy want %q got %q", word, want, found)
+		}
+	}
+	if !gs.Spell("walk") {
+		t.Errorf("word forms lost")
+	}
+
+	sqlDB, err := gs.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	if _, err := gs.Suggest("walz", 0); err == nil {
+		t.Errorf("Suggest of a closed database without error")
+	}
+}
+
+func TestGormStoreSchema(t *testing.T) {
+	// a word form table as created before the case of the words was
+	// kept: mixed case words have no Original
+	type legacyWordForm struct {
+		ID   uint   `gorm:"primaryKey"`
+		Word string `gorm:"index"`
+		Lang string
+		Case WordCase
+	}
+	db := createTable(filepath.Join(t.TempDir(), "xx.db"), true, nil)
+	if db == nil {
+		t.Fatalf("Unable to create database")
+	}
+	if err := db.Migrator().DropTable(&WordForm{}); err != nil {
+		t.Fatalf("Unable to drop table: %s", err)
+	}
+	legacy := db.Table("word_forms")
+	if err := legacy.Migrator().CreateTable(&legacyWordForm{}); err != nil {
+		t.Fatalf("Unable to create table: %s", err)
+	}
+	rows := []legacyWordForm{{Word: "walk", Case: AllLower}, {Word: "iphone", Case: Mixed}}
+	if err := legacy.Create(&rows).Error; err != nil {
+		t.Fatalf("Unable to add rows: %s", err)
+	}
+	if err := db.Create(&Preferences{Dict: `{"lang":"en"}`}).Error; err != nil {
+		t.Fatalf("Unable to add preferences: %s", err)
+	}
+
+	gs, err := NewGoSpellDBReader(db)
+	if err != nil {
+		t.Fatalf("NewGoSpellDBReader: %v", err)
+	}
+	for word, want := range map[string]bool{"walk": true, "Walk": true, "iphone": true, "walks": false} {
+		if gs.Spell(word) != want {
+			t.Errorf("%q was not %v", word, want)
+		}
+	}
+	wfs, err := gs.Store.Lookup("walk")
+	if err != nil || len(wfs) != 1 || wfs[0].Phonetic != metaphone("walk") {
+		t.Errorf("Lookup want the phonetic code of walk got %+v %v", wfs, err)
+	}
+}
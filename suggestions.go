@@ -1,51 +1,866 @@
 package gospell
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// SpellWithSuggestions — проверка слова и получение для него возможных замен
+// MaxSuggestions is the number of suggestions returned by GetSuggestions
+// and SpellWithSuggestions
+const MaxSuggestions = 15
+
+// maxMapChanges limits the MAP characters replaced in a candidate
+const maxMapChanges = 2
+
+// defaultKeyboardRows are the neighbour keys without KEY, as in hunspell
+var defaultKeyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// Generator names how a suggestion was found
+type Generator string
+
+// Generators of the suggestions, the first ones are the most likely
+const (
+	GeneratorCase     Generator = "case"     // the word in another case
+	GeneratorREP      Generator = "REP"      // REP replacements
+	GeneratorMAP      Generator = "MAP"      // related characters, as accented letters
+	GeneratorKEY      Generator = "KEY"      // neighbour keys
+	GeneratorTypo     Generator = "typo"     // single character edits
+	GeneratorPhonetic Generator = "phonetic" // sound alike words, see PhoneticStore
+	GeneratorSplit    Generator = "split"    // two words
+	GeneratorStore    Generator = "store"    // close words found by the store
+)
+
+// generatorOrder ranks the suggestions of the same score by generator
+var generatorOrder = map[Generator]int{GeneratorCase: 0, GeneratorREP: 1, GeneratorMAP: 2, GeneratorKEY: 3, GeneratorTypo: 4, GeneratorPhonetic: 5, GeneratorSplit: 6, GeneratorStore: 7}
+
+// Edit operations of a Suggestion
+const (
+	EditInsert  = "insert"
+	EditDelete  = "delete"
+	EditReplace = "replace"
+	EditSwap    = "swap" // of two neighbour characters
+)
+
+// Edit is an edit operation turning a misspelled word into a
+// suggestion
+type Edit struct {
+	Op   string // EditInsert, EditDelete, EditReplace or EditSwap
+	Pos  int    // position in the misspelled word, in characters
+	From string // characters of the misspelled word
+	To   string // characters of the suggestion
+}
+
+// Suggestion is a correction of a misspelled word, see SuggestDetailed
+type Suggestion struct {
+	Word      string
+	Score     float64 // likelihood, the higher the better
+	Edits     []Edit
+	Generator Generator
+}
+
+// SpellWithSuggestions — проверка слова и получение для него возможных
+// замен, ошибки хранилища словоформ игнорируются, см. Suggest
 func (s *GoSpell) SpellWithSuggestions(word string) (suggestions []string) {
 	if s.Spell(word) {
 		return
 	}
-	return s.GetSuggestions(word)
+	suggestions, _ = s.Suggest(word, MaxSuggestions)
+	return suggestions
 }
 
-// GetSuggestions - Поиск возможных подстановок
+// GetSuggestions - Поиск возможных подстановок, ошибки хранилища
+// словоформ игнорируются
+//
+// Deprecated: use Suggest, which returns the errors of the store.
 func (s *GoSpell) GetSuggestions(word string) []string {
+	suggestions, _ := s.Suggest(word, MaxSuggestions)
+	return suggestions
+}
+
+// Suggest returns at most limit corrections of a misspelled word, the
+// most likely first, or all of them if limit is not positive, see
+// SuggestDetailed
+func (s *GoSpell) Suggest(word string, limit int) ([]string, error) {
+	detailed, err := s.SuggestDetailed(word, limit)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]string, len(detailed))
+	for i, d := range detailed {
+		suggestions[i] = d.Word
+	}
+	return suggestions, nil
+}
+
+// SuggestDetailed returns at most limit corrections of a misspelled
+// word with their score, the most likely first, or all of them if
+// limit is not positive
+//
+//	Like hunspell, the candidates are the word in another case, as
+//	the KEEPCASE "Bq" for "BQ", and are made by the REP replacements,
+//	the MAP related characters, the KEY neighbour keys, by swapping,
+//	deleting, doubling and undoubling characters, inserting and
+//	replacing the TRY characters, by the words sounding alike, see
+//	PhoneticStore, and by splitting the word in two, the candidates of
+//	each generator are looked up at once in a BatchStore.  Stores able
+//	to search for close words, see WordStore, add the words one edit
+//	away.  The score decreases with the weight of the edits, see
+//	align, the REP, MAP and KEY ones weigh the least, and increases
+//	with the Frequency of the word.  The suggestions are written in
+//	the case of the word, see matchCase.  The first error of the store
+//	is returned
+func (s *GoSpell) SuggestDetailed(word string, limit int) ([]Suggestion, error) {
 	if s.Spell(word) || s.Spell(strings.ToLower(word)) {
-		return []string{}
+		return []Suggestion{}, nil
 	}
 
 	if isLatinNumber(word) || isInitial(word) {
-		return []string{}
+		return []Suggestion{}, nil
+	}
+
+	// capitalized words are corrected in lower case, the case of the
+	// word is restored by matchCase
+	sg := suggester{s: s, word: word, typed: word, style: CaseStyle(word), seen: map[string]struct{}{word: {}}}
+	if sg.style == Title || sg.style == AllUpper {
+		sg.word = strings.ToLower(word)
+	}
+	if batch, ok := s.Store.(BatchStore); ok {
+		// the candidates are looked up by generator, see flush
+		cached := *s
+		sg.cache = &batchCache{BatchStore: batch, forms: make(map[string][]WordForm)}
+		cached.Store = sg.cache
+		sg.s = &cached
+	}
+	maps := func() { sg.maps(sg.word, 0, maxMapChanges) }
+	for _, generate := range []func(){sg.cases, sg.reps, maps, sg.keys, sg.edits, sg.doubles, sg.phonetics, sg.splits} {
+		generate()
+		sg.flush()
+	}
+
+	// the memory store enumerates every word to search them, the
+	// edits above are faster
+	if _, slow := s.Store.(*memoryStore); !slow && s.Store != nil {
+		lower := strings.ToLower(word)
+		var words []string
+		err := s.Store.Fuzzy(lower, 1, func(wf WordForm, dist int) bool {
+			if s.suggestableForm(wf) {
+				words = append(words, wf.original())
+			}
+			return true
+		})
+		sg.fail(err)
+		// without a hyphen and the following character
+		if reHyphenAndSymbol.MatchString(lower) {
+			v := reHyphenAndSymbol.ReplaceAllString(lower, "")
+			wfs, err := s.Store.Lookup(v)
+			sg.fail(err)
+			for _, wf := range wfs {
+				if s.suggestableForm(wf) {
+					words = append(words, wf.original())
+				}
+			}
+		}
+		sg.prefetch(words, false)
+		for _, w := range words {
+			sg.add(w)
+		}
+	}
+
+	if sg.err != nil {
+		return nil, sg.err
+	}
+
+	suggestions := make([]Suggestion, len(sg.found))
+	for i, f := range sg.found {
+		suggestions[i] = Suggestion{
+			Word:      f.word,
+			Score:     s.score(f),
+			Edits:     f.edits,
+			Generator: f.generator,
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.Generator != b.Generator:
+			return generatorOrder[a.Generator] < generatorOrder[b.Generator]
+		}
+		return a.Word < b.Word
+	})
+
+	out := suggestions[:0]
+	seen := map[string]struct{}{}
+	for _, sugg := range suggestions {
+		sugg.Word = s.OutputConversion(sugg.Word)
+		if _, ok := seen[sugg.Word]; ok {
+			continue
+		}
+		seen[sugg.Word] = struct{}{}
+		out = append(out, sugg)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// matchCase writes a correction as the misspelled word of the given
+// case style: capitalized or in upper case
+//
+//	Words of Mixed case in the dictionary, as "iPhone", and KEEPCASE
+//	words are written as in the dictionary.  Only the first word of a
+//	split suggestion is capitalized
+func (s *GoSpell) matchCase(candidate string, style WordCase) string {
+	if style != Title && style != AllUpper {
+		return candidate
+	}
+	words := strings.Split(candidate, " ")
+	for i, w := range words {
+		if i > 0 && style == Title {
+			break
+		}
+		keep := CaseStyle(w) == Mixed
+		for _, wf := range s.lookupForms(w) {
+			keep = keep || wf.KeepCase || wf.Case == Mixed
+		}
+		if !keep {
+			words[i] = applyCase(strings.ToLower(w), style)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// score is the likelihood of a suggestion: 1 / (1 + weight) for the
+// words without a Frequency, more for the frequent ones
+func (s *GoSpell) score(f suggestion) float64 {
+	score := 1 / float64(1+f.weight)
+	if n := s.frequency(f.word); n > 0 {
+		score *= 1 + math.Log(float64(1+n))
+	}
+	return score
+}
+
+// frequency returns the Frequency of the word, or of its lower case,
+// the words of a split suggestion count as the rarest of them
+func (s *GoSpell) frequency(word string) int {
+	if len(s.Frequency) == 0 {
+		return 0
+	}
+	rarest := -1
+	for _, w := range strings.Split(word, " ") {
+		n, ok := s.Frequency[w]
+		if !ok {
+			n = s.Frequency[strings.ToLower(w)]
+		}
+		if rarest == -1 || n < rarest {
+			rarest = n
+		}
+	}
+	return rarest
+}
+
+// AddFrequencyListFile reads a word frequency list, see AddFrequencyList
+func (s *GoSpell) AddFrequencyListFile(name string) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return s.AddFrequencyList(fd)
+}
+
+// AddFrequencyList adds word counts ranking the suggestions, one word
+// and its count separated by spaces per line, as "the 23135851162"
+//
+//	Assumed to be in UTF-8, the counts of duplicated words are added
+func (s *GoSpell) AddFrequencyList(r io.Reader) error {
+	if s.Frequency == nil {
+		s.Frequency = make(map[string]int)
+	}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("frequency list line %d had %d fields, expected 2", line, len(fields))
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("frequency list line %d had %q expected count", line, fields[1])
+		}
+		s.Frequency[fields[0]] += n
+	}
+	return scanner.Err()
+}
+
+// suggestion is a correction found by a suggester
+type suggestion struct {
+	word      string
+	weight    int // see align
+	edits     []Edit
+	generator Generator
+}
+
+// candidate is a word tried by a generator
+type candidate struct {
+	word string
+	gen  Generator
+}
+
+// suggester collects the corrections of a misspelled word
+type suggester struct {
+	s       *GoSpell
+	word    string              // the word corrected, in lower case if capitalized
+	typed   string              // the misspelled word
+	style   WordCase            // of the misspelled word
+	seen    map[string]struct{} // candidates already tried
+	found   []suggestion
+	err     error       // first error of the store
+	cache   *batchCache // store of s over a BatchStore, if any
+	pending []candidate // candidates waiting for the next flush
+}
+
+// batchCache is the store of the suggestions over a BatchStore: the
+// words prefetched at once are then looked up in memory
+type batchCache struct {
+	BatchStore
+	forms map[string][]WordForm // by lower case word, nil if not found
+}
+
+func (c *batchCache) Lookup(word string) ([]WordForm, error) {
+	if wfs, ok := c.forms[word]; ok {
+		return wfs, nil
+	}
+	wfs, err := c.BatchStore.Lookup(word)
+	if err == nil {
+		c.forms[word] = wfs
+	}
+	return wfs, err
+}
+
+// Phonetic is the one of the store, if it is a PhoneticStore
+func (c *batchCache) Phonetic(code string, fn func(wf WordForm) bool) error {
+	if ps, ok := c.BatchStore.(PhoneticStore); ok {
+		return ps.Phonetic(code, fn)
+	}
+	return nil
+}
+
+// prefetch looks up at once the words of the candidates not looked up
+// yet.  With compounds, the parts Decompose starts with are looked up
+// as well
+func (sg *suggester) prefetch(candidates []string, compounds bool) {
+	if sg.cache == nil {
+		return
+	}
+	s := sg.s
+	compoundMin := s.Config.CompoundMin
+	var keys []string
+	add := func(word string) {
+		for _, key := range s.lookupKeys(word) {
+			if _, ok := sg.cache.forms[key]; !ok {
+				sg.cache.forms[key] = nil
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, c := range candidates {
+		for _, w := range strings.Split(c, " ") {
+			add(w)
+			if !compounds {
+				continue
+			}
+			n := 0
+			for i := range w {
+				if n >= compoundMin && utf8.RuneCountInString(w[i:]) >= compoundMin {
+					add(w[:i])
+					add(w[i:])
+				}
+				n++
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	found, err := sg.cache.LookupAll(keys)
+	sg.fail(err)
+	for key, wfs := range found {
+		sg.cache.forms[key] = wfs
+	}
+}
+
+// fail keeps the first error of the store
+func (sg *suggester) fail(err error) {
+	if sg.err == nil {
+		sg.err = err
+	}
+}
+
+// try adds the candidate made by gen if it is a correct word, with a
+// BatchStore it is checked by the next flush
+func (sg *suggester) try(word string, gen Generator) {
+	if _, ok := sg.seen[word]; ok {
+		return
+	}
+	sg.seen[word] = struct{}{}
+	if sg.cache != nil {
+		sg.pending = append(sg.pending, candidate{word, gen})
+		return
+	}
+	sg.check(word, gen)
+}
+
+// flush checks the candidates waiting since the last flush, their
+// words are looked up at once
+func (sg *suggester) flush() {
+	if len(sg.pending) == 0 {
+		return
+	}
+	words := make([]string, len(sg.pending))
+	for i, c := range sg.pending {
+		words[i] = c.word
+	}
+	a := &sg.s.Config
+	sg.prefetch(words, a.hasCompoundFlags() || len(sg.s.rules) > 0)
+	for _, c := range sg.pending {
+		sg.check(c.word, c.gen)
+	}
+	sg.pending = sg.pending[:0]
+}
+
+// check adds the candidate made by gen if it is a correct word
+func (sg *suggester) check(candidate string, gen Generator) {
+	spelling, ok := sg.suggestable(candidate)
+	if !ok {
+		return
+	}
+	// phonetic candidates are dictionary spellings, their case is
+	// weighed apart from the cap below as for the other candidates
+	base := candidate
+	if sg.style != Mixed {
+		base = strings.ToLower(candidate)
+	}
+	weight, _ := align(sg.word, base)
+	switch gen {
+	case GeneratorREP, GeneratorMAP, GeneratorKEY:
+		// known misspellings rank with the cheapest typos
+		if weight > 1 {
+			weight = 1
+		}
+	case GeneratorPhonetic:
+		if weight > 2 {
+			weight = 2
+		}
+	case GeneratorSplit:
+		// dictionaries as en_US list every letter as a word, the splits
+		// into a letter and a word, as "x house", rank after the edits
+		for _, part := range strings.Split(base, " ") {
+			if utf8.RuneCountInString(part) == 1 {
+				weight++
+				break
+			}
+		}
+	}
+	// dictionary words of another case, as "NEH" for "teh", weigh more
+	spelling = sg.s.matchCase(spelling, sg.style)
+	if spelling == sg.typed {
+		// a case change of the word, as "3Th" for "3th"
+		return
+	}
+	weight += caseChanges(applyCase(base, sg.style), spelling)
+	_, edits := align(sg.typed, spelling)
+	sg.found = append(sg.found, suggestion{spelling, weight, edits, gen})
+}
+
+// add adds a word found in the store, unless a forbidden homonym
+// forbids it
+func (sg *suggester) add(word string) {
+	if sg.s.IsForbidden(word) {
+		return
+	}
+	word = sg.s.matchCase(word, sg.style)
+	if word == sg.typed {
+		return
+	}
+	for _, f := range sg.found {
+		if f.word == word {
+			return
+		}
+	}
+	sg.seen[word] = struct{}{}
+	weight, edits := align(sg.typed, word)
+	sg.found = append(sg.found, suggestion{word, weight, edits, GeneratorStore})
+}
+
+// cases tries the dictionary spellings of the word in another case,
+// "Paris" for "paris" or the KEEPCASE "Bq" for "BQ", the typed word
+// itself was marked as seen
+func (sg *suggester) cases() {
+	lower := strings.ToLower(sg.word)
+	delete(sg.seen, lower)
+	sg.try(lower, GeneratorCase)
+}
+
+// reps tries the REP replacements, "^" and "$" anchor them to the
+// start and the end of the word and "_" is a space
+func (sg *suggester) reps() {
+	word := sg.word
+	for _, rep := range sg.s.Config.Replacements {
+		from, to := rep[0], strings.ReplaceAll(rep[1], "_", " ")
+		start := strings.HasPrefix(from, "^")
+		from = strings.TrimPrefix(from, "^")
+		end := strings.HasSuffix(from, "$")
+		from = strings.TrimSuffix(from, "$")
+		switch {
+		case from == "":
+		case start && end:
+			if word == from {
+				sg.try(to, GeneratorREP)
+			}
+		case start:
+			if strings.HasPrefix(word, from) {
+				sg.try(to+word[len(from):], GeneratorREP)
+			}
+		case end:
+			if strings.HasSuffix(word, from) {
+				sg.try(word[:len(word)-len(from)]+to, GeneratorREP)
+			}
+		default:
+			for k := strings.Index(word, from); k != -1; {
+				sg.try(word[:k]+to+word[k+len(from):], GeneratorREP)
+				next := strings.Index(word[k+1:], from)
+				if next == -1 {
+					break
+				}
+				k += next + 1
+			}
+		}
 	}
+}
 
-	sqlWords := []string{}
-	letters := strings.Split(word, "")
-	for i := range letters {
-		s := "`word` LIKE '" + substr(word, 0, i) + "_" + substr(word, i+1, len([]rune(word))) + "'"
-		sqlWords = append(sqlWords, s)
+// maps tries replacing the MAP related characters of word from the
+// byte from on, at most changes of them
+func (sg *suggester) maps(word string, from, changes int) {
+	for i := from; i < len(word); {
+		for _, related := range sg.s.Config.Maps {
+			for _, c := range related {
+				if !strings.HasPrefix(word[i:], c) {
+					continue
+				}
+				for _, r := range related {
+					// case is not a related character
+					if r == c || (strings.ToLower(r) == r) != (strings.ToLower(c) == c) {
+						continue
+					}
+					candidate := word[:i] + r + word[i+len(c):]
+					sg.try(candidate, GeneratorMAP)
+					if changes > 1 {
+						sg.maps(candidate, i+len(r), changes-1)
+					}
+				}
+			}
+		}
+		_, size := utf8.DecodeRuneInString(word[i:])
+		i += size
 	}
-	sqlWords = append(sqlWords, "`word` LIKE '"+substr(word, 1, len([]rune(word)))+"'")
-	if reHyphenAndSymbol.MatchString(word) {
-		v := reHyphenAndSymbol.ReplaceAllString(word, "")
-		sqlWords = append(sqlWords, "`word` LIKE '"+v+"'")
+}
+
+// keys tries replacing every character with its neighbour keys in
+// the KEY rows
+func (sg *suggester) keys() {
+	rows := sg.s.Config.KeyboardRows
+	if len(rows) == 0 {
+		rows = defaultKeyboardRows
+	}
+	runes := []rune(sg.word)
+	buf := make([]rune, len(runes))
+	for _, row := range rows {
+		keys := []rune(row)
+		for k, key := range keys {
+			for i, r := range runes {
+				if r != key {
+					continue
+				}
+				copy(buf, runes)
+				if k > 0 {
+					buf[i] = keys[k-1]
+					sg.try(string(buf), GeneratorKEY)
+				}
+				if k+1 < len(keys) {
+					buf[i] = keys[k+1]
+					sg.try(string(buf), GeneratorKEY)
+				}
+			}
+		}
 	}
+}
 
-	var founds []WordForm
-	condition := strings.Join(sqlWords, " OR ")
-	result := s.DB.Where(condition).Select("word").Order("word asc").Find(&founds)
+// edits tries the single character edits of the word: swapped
+// neighbours, deleted characters, and inserted or replaced TRY
+// characters
+func (sg *suggester) edits() {
+	runes := []rune(sg.word)
+	try := []rune(sg.s.Config.TryChars)
+	buf := make([]rune, 0, len(runes)+1)
+	for i := range runes {
+		if i > 0 && runes[i] != runes[i-1] {
+			buf = append(buf[:0], runes...)
+			buf[i-1], buf[i] = buf[i], buf[i-1]
+			sg.try(string(buf), GeneratorTypo)
+		}
+		sg.try(string(append(append(buf[:0], runes[:i]...), runes[i+1:]...)), GeneratorTypo)
+	}
+	for i := 0; i <= len(runes); i++ {
+		for _, c := range try {
+			buf = append(append(buf[:0], runes[:i]...), c)
+			sg.try(string(append(buf, runes[i:]...)), GeneratorTypo)
+			if i < len(runes) && c != runes[i] {
+				buf = append(append(buf[:0], runes[:i]...), c)
+				sg.try(string(append(buf, runes[i+1:]...)), GeneratorTypo)
+			}
+		}
+	}
+}
 
-	variants := []string{}
-	if result.Error == nil {
-		for _, suggestion := range founds {
-			variants = append(variants, suggestion.Word)
+// doubles tries doubling every character, which the TRY characters
+// may miss, and dropping a repeated pair of characters, as in
+// "vacacation"
+func (sg *suggester) doubles() {
+	runes := []rune(sg.word)
+	buf := make([]rune, 0, len(runes)+1)
+	for i := range runes {
+		buf = append(append(buf[:0], runes[:i+1]...), runes[i:]...)
+		sg.try(string(buf), GeneratorTypo)
+		if i >= 3 && runes[i] == runes[i-2] && runes[i-1] == runes[i-3] {
+			sg.try(string(append(append(buf[:0], runes[:i-1]...), runes[i+1:]...)), GeneratorTypo)
 		}
 	}
+}
 
-	return variants
+// phonetics tries the words sounding as the word, not too different
+// in writing
+func (sg *suggester) phonetics() {
+	s := sg.s
+	ps, ok := s.Store.(PhoneticStore)
+	if !ok || s.phonetic == nil {
+		return
+	}
+	lower := strings.ToLower(sg.word)
+	code := s.phonetic(lower)
+	if code == "" {
+		return
+	}
+	maxDist := utf8.RuneCountInString(lower)/2 + 1
+	try := func(wf WordForm) bool {
+		if _, ok := withinDistance(lower, wf.Word, maxDist); ok && s.suggestableForm(wf) {
+			sg.try(wf.original(), GeneratorPhonetic)
+		}
+		return true
+	}
+	sg.fail(ps.Phonetic(code, try))
+	if ms, ok := s.Store.(*memoryStore); ok {
+		ms.phoneticAffixed(lower, try)
+	}
+}
+
+// SuggestJoin returns the correct word made of an unknown word and
+// the next one, "accommodate" for "accom" and "modate", or the two
+// joined by a hyphen
+//
+//	Forbidden and NOSUGGEST words are never returned.  SpellFile
+//	joins the neighbour unknown words of a line with it
+func (s *GoSpell) SuggestJoin(word, next string) (string, bool) {
+	if word == "" || next == "" {
+		return "", false
+	}
+	for _, joined := range []string{word + next, word + "-" + next} {
+		if !s.Spell(joined) {
+			continue
+		}
+		sg := suggester{s: s, word: joined, typed: joined, style: CaseStyle(joined)}
+		if _, ok := sg.suggestable(joined); ok && sg.err == nil {
+			return joined, true
+		}
+	}
+	return "", false
+}
+
+// splits tries two correct words split by a space, a part of one
+// letter weighs more, see check
+func (sg *suggester) splits() {
+	for i, r := range sg.word {
+		if i > 0 && r != '-' && sg.word[i-1] != '-' {
+			sg.try(sg.word[:i]+" "+sg.word[i:], GeneratorSplit)
+		}
+	}
+}
+
+// suggestable returns the spelling of the candidate to suggest, if
+// it is a correct word of the dictionary or the personal words, a
+// compound, or several such words split by spaces.  Forbidden and
+// NOSUGGEST words, and the compounds of NOSUGGEST words, are correct
+// but never suggested
+//
+//	The dictionary words are found in any case and spelled as in the
+//	dictionary, "Paris" for "paris" and "meh" for "Meh", matchCase
+//	then writes them in the case of the misspelled word
+func (sg *suggester) suggestable(candidate string) (string, bool) {
+	if words := strings.Split(candidate, " "); len(words) > 1 {
+		for i, w := range words {
+			spelling, ok := sg.suggestable(w)
+			if w == "" || !ok {
+				return "", false
+			}
+			words[i] = spelling
+		}
+		return strings.Join(words, " "), true
+	}
+	s := sg.s
+	wfs, err := s.findForms(candidate)
+	sg.fail(err)
+	spelling := ""
+	for _, wf := range wfs {
+		original := wf.original()
+		if !s.suggestableForm(wf) || s.isForbidden(original, wfs) {
+			continue
+		}
+		// homonyms as "a" and "A" are spelled as the candidate
+		if original == candidate {
+			return original, true
+		}
+		if spelling == "" {
+			spelling = original
+		}
+	}
+	if spelling != "" {
+		return spelling, true
+	}
+	parts := s.Decompose(candidate)
+	for _, part := range parts {
+		if sg.noSuggest(part) {
+			return "", false
+		}
+	}
+	return candidate, parts != nil
+}
+
+// suggestableForm returns true if the word form may be suggested on
+// its own: it is not forbidden, NOSUGGEST nor ONLYINCOMPOUND, which
+// Spell rejects as well
+func (s *GoSpell) suggestableForm(wf WordForm) bool {
+	if wf.Forbidden || wf.NoSuggest {
+		return false
+	}
+	return wf.Flags == "" || !hasFlag(parseFlagString(wf.Flags), s.Config.CompoundOnlyFlag)
+}
+
+// noSuggest returns true if the word has only NOSUGGEST forms
+func (sg *suggester) noSuggest(word string) bool {
+	wfs, err := sg.s.findForms(word)
+	sg.fail(err)
+	for _, wf := range wfs {
+		if !wf.NoSuggest {
+			return false
+		}
+	}
+	return len(wfs) > 0
+}
+
+// align returns the edits turning a misspelled word into a
+// correction and their weight, by how common they are as typos:
+// swapped neighbours, doubled or undoubled characters and wrong case
+// weigh 1, other inserted, deleted or replaced characters weigh 2
+func align(word, candidate string) (int, []Edit) {
+	a, b := []rune(word), []rune(candidate)
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+	}
+	for j := 1; j <= len(b); j++ {
+		d[0][j] = d[0][j-1] + insertWeight(b, j-1)
+	}
+	for i := 1; i <= len(a); i++ {
+		d[i][0] = d[i-1][0] + insertWeight(a, i-1)
+		for j := 1; j <= len(b); j++ {
+			d[i][j] = min3(d[i-1][j]+insertWeight(a, i-1), d[i][j-1]+insertWeight(b, j-1),
+				d[i-1][j-1]+replaceWeight(a[i-1], b[j-1]))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+
+	// the edits are found backwards from the end of both words
+	var edits []Edit
+	for i, j := len(a), len(b); i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && a[i-1] == b[j-1] && d[i][j] == d[i-1][j-1]:
+			i, j = i-1, j-1
+		case i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i][j] == d[i-2][j-2]+1:
+			edits = append(edits, Edit{EditSwap, i - 2, string(a[i-2 : i]), string(b[j-2 : j])})
+			i, j = i-2, j-2
+		case i > 0 && j > 0 && d[i][j] == d[i-1][j-1]+replaceWeight(a[i-1], b[j-1]):
+			edits = append(edits, Edit{EditReplace, i - 1, string(a[i-1]), string(b[j-1])})
+			i, j = i-1, j-1
+		case i > 0 && d[i][j] == d[i-1][j]+insertWeight(a, i-1):
+			edits = append(edits, Edit{EditDelete, i - 1, string(a[i-1]), ""})
+			i--
+		default:
+			edits = append(edits, Edit{EditInsert, i, "", string(b[j-1])})
+			j--
+		}
+	}
+	for l, r := 0, len(edits)-1; l < r; l, r = l+1, r-1 {
+		edits[l], edits[r] = edits[r], edits[l]
+	}
+	return d[len(a)][len(b)], edits
+}
+
+// caseChanges counts the characters of a written in another case in b
+func caseChanges(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) != len(rb) {
+		return 0
+	}
+	n := 0
+	for i := range ra {
+		if ra[i] != rb[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// replaceWeight is the cost of replacing the character a with b
+func replaceWeight(a, b rune) int {
+	switch {
+	case a == b:
+		return 0
+	case strings.EqualFold(string(a), string(b)):
+		return 1
+	}
+	return 2
+}
+
+// insertWeight is the cost of inserting or deleting the character i
+// of word, cheaper if it doubles the previous one
+func insertWeight(word []rune, i int) int {
+	if i > 0 && word[i] == word[i-1] {
+		return 1
+	}
+	return 2
 }
 
 type onlyWord struct {
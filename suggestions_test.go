@@ -0,0 +1,309 @@
+package gospell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vbatushev/gospell/plaintext"
+)
+
+func TestSuggest(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY esiantrolcdugmphbyfvkw
+
+SFX S Y 1
+SFX S 0 s .
+`
+	sampleDic := `12
+a
+address
+dress
+lot
+allot
+receive/S
+spelling
+the
+tea
+vacation
+which
+Paris
+`
+	gs, err := NewGoSpellReader(strings.NewReader(sampleAff), strings.NewReader(sampleDic), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	cases := []struct {
+		word  string
+		limit int
+		want  []string
+	}{
+		{"teh", 0, []string{"the", "tea"}},
+		{"recieves", 0, []string{"receives"}},
+		{"speling", 0, []string{"spelling"}},
+		{"vacacation", 0, []string{"vacation"}},
+		{"wich", 0, []string{"which"}},
+		{"Parsi", 0, []string{"Paris"}},
+		{"alot", 0, []string{"allot", "lot", "a lot"}},
+		{"alot", 1, []string{"allot"}},
+		{"adress", 3, []string{"address", "dress", "a dress"}},
+		{"the", 0, []string{}},
+	}
+	for _, c := range cases {
+		if got, err := gs.Suggest(c.word, c.limit); err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: want %v got %v %v", c.word, c.want, got, err)
+		}
+	}
+
+	detailed := []struct {
+		word string
+		want Suggestion
+	}{
+		{"teh", Suggestion{"the", 0.5, []Edit{{EditSwap, 1, "eh", "he"}}, GeneratorTypo}},
+		{"speling", Suggestion{"spelling", 0.5, []Edit{{EditInsert, 4, "", "l"}}, GeneratorTypo}},
+		{"recieves", Suggestion{"receives", 0.5, []Edit{{EditSwap, 3, "ie", "ei"}}, GeneratorTypo}},
+		// a one letter part ranks the split after the edits
+		{"alot", Suggestion{"a lot", 1.0 / 4, []Edit{{EditInsert, 1, "", " "}}, GeneratorSplit}},
+	}
+	for _, c := range detailed {
+		got, err := gs.SuggestDetailed(c.word, 0)
+		if err != nil {
+			t.Fatalf("%s: %s", c.word, err)
+		}
+		found := false
+		for _, sugg := range got {
+			if sugg.Word == c.want.Word {
+				found = reflect.DeepEqual(sugg, c.want)
+				if !found {
+					t.Errorf("%s: want %+v got %+v", c.word, c.want, sugg)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: %s not suggested in %+v", c.word, c.want.Word, got)
+		}
+	}
+
+	if err := gs.AddFrequencyList(strings.NewReader("tea 1000000\nthe 10\n")); err != nil {
+		t.Fatalf("Unable to read frequencies: %s", err)
+	}
+	if got, _ := gs.Suggest("teh", 0); !reflect.DeepEqual(got, []string{"tea", "the"}) {
+		t.Errorf("teh: frequent words first want [tea the] got %v", got)
+	}
+	if err := gs.AddFrequencyList(strings.NewReader("tea many\n")); err == nil {
+		t.Errorf("frequency list error not reported")
+	}
+}
+
+func TestSuggestTables(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+KEY qwertyuiop|asdfghjkl|zxcvbnm
+MAP 2
+MAP eéè
+MAP ß(ss)
+REP 2
+REP ks cc
+REP ^alot$ a_lot
+`
+	sampleDic := `6
+a
+accent
+café
+lot
+straße
+jump
+`
+	gs, err := NewGoSpellReader(strings.NewReader(sampleAff), strings.NewReader(sampleDic), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	if want := [][]string{{"e", "é", "è"}, {"ß", "ss"}}; !reflect.DeepEqual(gs.Config.Maps, want) {
+		t.Errorf("MAP want %q got %q", want, gs.Config.Maps)
+	}
+	if len(gs.Config.KeyboardRows) != 3 {
+		t.Errorf("KEY want 3 rows got %q", gs.Config.KeyboardRows)
+	}
+	cases := []struct {
+		word, want string
+		gen        Generator
+	}{
+		{"aksent", "accent", GeneratorREP},
+		{"alot", "a lot", GeneratorREP},
+		{"cafe", "café", GeneratorMAP},
+		{"strasse", "straße", GeneratorMAP},
+		{"jumo", "jump", GeneratorKEY},
+	}
+	for _, c := range cases {
+		sg := suggester{s: gs, word: c.word, typed: c.word, seen: map[string]struct{}{}}
+		sg.reps()
+		sg.maps(c.word, 0, maxMapChanges)
+		sg.keys()
+		if len(sg.found) != 1 || sg.found[0].word != c.want || sg.found[0].generator != c.gen {
+			t.Errorf("%s: want %s from %s got %+v", c.word, c.want, c.gen, sg.found)
+		}
+		if got, err := gs.Suggest(c.word, 1); err != nil || !reflect.DeepEqual(got, []string{c.want}) {
+			t.Errorf("%s: suggestions want %s got %v %v", c.word, c.want, got, err)
+		}
+	}
+}
+
+func TestSuggestCase(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY esianrtolcdugmphbyfvkwz
+KEEPCASE K
+`
+	sampleDic := `8
+Moskva
+bank
+iPhone
+the
+a
+lot
+gospell/K
+Bq/K
+`
+	for _, gs := range newTestSpellers(t, sampleAff, sampleDic) {
+		name := storeName(gs)
+		// the case changes weigh as edits
+		for _, c := range []struct {
+			word, want string
+			score      float64
+			gen        Generator
+		}{
+			{"Moskwa", "Moskva", 1.0 / 3, GeneratorTypo},
+			{"MOSKWA", "MOSKVA", 1.0 / 3, GeneratorTypo},
+			{"moskwa", "Moskva", 1.0 / 4, GeneratorTypo},
+			{"BANQ", "BANK", 1.0 / 3, GeneratorTypo},
+			{"Banq", "Bank", 1.0 / 3, GeneratorTypo},
+			{"IPHONR", "iPhone", 1.0 / 7, GeneratorKEY},
+			{"Iphonr", "iPhone", 1.0 / 4, GeneratorKEY},
+			{"TEH", "THE", 1.0 / 2, GeneratorTypo},
+			{"Teh", "The", 1.0 / 2, GeneratorTypo},
+			{"Thelot", "The lot", 1.0 / 3, GeneratorSplit},
+			{"THELOT", "THE LOT", 1.0 / 3, GeneratorSplit},
+			{"GOSPEL", "gospell", 1.0 / 9, GeneratorTypo},
+			{"Gospel", "gospell", 1.0 / 3, GeneratorTypo},
+			{"gospelk", "gospell", 1.0 / 2, GeneratorKEY},
+			{"moskva", "Moskva", 1.0 / 2, GeneratorCase},
+			{"BQ", "Bq", 1.0 / 2, GeneratorCase},
+			{"bq", "Bq", 1.0 / 2, GeneratorCase},
+		} {
+			got, err := gs.SuggestDetailed(c.word, 0)
+			if err != nil || len(got) != 1 || got[0].Word != c.want || got[0].Score != c.score || got[0].Generator != c.gen {
+				t.Errorf("%s: %s want %s %v from %s got %+v %v", name, c.word, c.want, c.score, c.gen, got, err)
+			}
+		}
+	}
+}
+
+func TestSuggestNoSuggest(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY esianrtolcdugmphbyfvkwz
+NOSUGGEST N
+FORBIDDENWORD F
+COMPOUNDFLAG C
+ONLYINCOMPOUND O
+
+SFX S Y 1
+SFX S 0 s .
+`
+	sampleDic := `8
+damn/NS
+dame/S
+walk/S
+walks/F
+shit/NC
+foot/C
+ball/C
+wurst/CO
+`
+	for _, gs := range newTestSpellers(t, sampleAff, sampleDic) {
+		name := storeName(gs)
+		for _, word := range []string{"damn", "damns", "Damn", "shit", "shitball"} {
+			if !gs.Spell(word) {
+				t.Errorf("%s: %s want correct", name, word)
+			}
+		}
+		for _, word := range []string{"walks", "wurst"} {
+			if gs.Spell(word) {
+				t.Errorf("%s: %s want incorrect", name, word)
+			}
+		}
+		// damn, shit, walks and wurst are never suggested
+		for word, want := range map[string][]string{
+			"damne":   {"dame"},
+			"damnes":  {"dames"},
+			"shitbal": {},
+			"footbal": {"football"},
+			"walkz":   {"walk"},
+			"wurts":   {},
+			"wurst":   {},
+			"Wurst":   {},
+		} {
+			got, err := gs.Suggest(word, MaxSuggestions)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s want %v got %v %v", name, word, want, got, err)
+			}
+		}
+	}
+}
+
+func TestSuggestJoin(t *testing.T) {
+	sampleAff := `
+SET UTF-8
+TRY esianrtolcdugmphbyfvkwz
+NOSUGGEST N
+`
+	sampleDic := `8
+thank
+you
+accommodate
+e-mail
+some
+thing
+something
+bollocks/N
+`
+	gs, err := NewGoSpellReader(strings.NewReader(sampleAff), strings.NewReader(sampleDic), nil, "")
+	if err != nil {
+		t.Fatalf("Unable to create GoSpell: %s", err)
+	}
+	if got, err := gs.Suggest("thankyou", 0); err != nil || !reflect.DeepEqual(got, []string{"thank you"}) {
+		t.Errorf("thankyou: want [thank you] got %v %v", got, err)
+	}
+
+	cases := []struct {
+		word, next string
+		want       string
+	}{
+		{"accom", "modate", "accommodate"},
+		{"Accom", "modate", "Accommodate"},
+		{"ACCOM", "MODATE", "ACCOMMODATE"},
+		{"e", "mail", "e-mail"},
+		{"some", "thing", "something"},
+		{"bol", "locks", ""},
+		{"accom", "", ""},
+		{"acom", "modate", ""},
+	}
+	for _, c := range cases {
+		got, ok := gs.SuggestJoin(c.word, c.next)
+		if got != c.want || ok != (c.want != "") {
+			t.Errorf("%s %s: want %q got %q %v", c.word, c.next, c.want, got, ok)
+		}
+	}
+
+	pt, _ := plaintext.NewIdentity()
+	diffs := SpellFile(gs, pt, []byte("thank you, accom modate\nacom modate"))
+	joins := make([]string, len(diffs))
+	for i, d := range diffs {
+		joins[i] = d.Original + ":" + d.Join
+	}
+	want := []string{"accom:accommodate", "modate:", "acom:", "modate:"}
+	if !reflect.DeepEqual(joins, want) {
+		t.Errorf("SpellFile want %v got %v", want, joins)
+	}
+}